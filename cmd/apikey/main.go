@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/Ayano2000/push/internal/config"
+	"github.com/Ayano2000/push/internal/pkg/auth"
+	"github.com/Ayano2000/push/internal/pkg/storage"
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/google/uuid"
+	"os"
+)
+
+const usage = `Usage:
+  apikey <development|production> create <name> <team>:<role>...  create a key, printing it
+  apikey <development|production> revoke <id>                     revoke a key
+
+Roles are viewer, editor or admin, a team of '*' grants the role on every team`
+
+// apikey manages the keys of the management API, it is how the first key is created
+func main() {
+	if len(os.Args) < 4 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	conf, err := config.NewConfig(os.Args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to load Config: %v\n", err)
+		os.Exit(1)
+	}
+
+	db, err := storage.NewPostgresDB(conf)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to connect to the database: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	switch os.Args[2] {
+	case "create":
+		err = create(context.Background(), db, os.Args[3], os.Args[4:])
+	case "revoke":
+		err = storage.RevokeAPIKey(context.Background(), db, os.Args[3])
+		if errors.Is(err, sql.ErrNoRows) {
+			err = fmt.Errorf("no unrevoked key with id %s", os.Args[3])
+		}
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to %s API key: %v\n", os.Args[2], err)
+		os.Exit(1)
+	}
+}
+
+// create stores a new API key with the given grants and prints
+// it, the key can't be recovered later
+func create(ctx context.Context, db storage.DatabaseHandler, name string, grants []string) error {
+	apiKey := types.APIKey{Name: name, Grants: make([]types.Grant, 0, len(grants))}
+	for _, value := range grants {
+		grant, err := types.ParseGrant(value)
+		if err != nil {
+			return err
+		}
+		apiKey.Grants = append(apiKey.Grants, grant)
+	}
+	if len(apiKey.Grants) == 0 {
+		return errors.New("a key needs at least one <team>:<role> grant")
+	}
+
+	key, err := auth.NewKey()
+	if err != nil {
+		return err
+	}
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+
+	apiKey.ID = id.String()
+	if err = storage.InsertAPIKey(ctx, db, apiKey, auth.HashKey(key)); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "Created API key %s (%s):\n%s\n", id, name, key)
+	return nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/Ayano2000/push/internal/config"
 	"github.com/Ayano2000/push/internal/handlers"
@@ -8,6 +10,8 @@ import (
 	"github.com/Ayano2000/push/internal/pkg/router"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
@@ -38,13 +42,29 @@ func main() {
 		os.Exit(1)
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// deliver queued forwards in the background, any left
+	// unfinished are picked up again on the next start
+	waitForwarder := handler.Services.Forwarder.Start(ctx)
+	defer waitForwarder()
+
 	server := http.Server{
 		Addr:    conf.ServerAddress,
 		Handler: dmux,
 	}
 
+	go func() {
+		<-ctx.Done()
+		if err := server.Shutdown(context.Background()); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to shutdown server: %v\n", err)
+		}
+	}()
+
 	fmt.Fprintf(os.Stdout, "Server is running on: %s", conf.ServerAddress)
-	if err := server.ListenAndServe(); err != nil {
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		fmt.Fprintf(os.Stdout, "Failed to start server: %v", err)
+		stop()
 	}
 }
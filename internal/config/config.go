@@ -2,7 +2,10 @@ package config
 
 import (
 	"github.com/joho/godotenv"
+	"github.com/pkg/errors"
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
@@ -13,6 +16,30 @@ type Config struct {
 	MinioSecretKey string
 	MinioUseSSL    bool
 	ServerAddress  string
+
+	// ForwardTimeout bounds a single request made to a webhooks forward_to target
+	ForwardTimeout time.Duration
+	// ForwardMaxAttempts is the number of requests made before a delivery is failed
+	ForwardMaxAttempts int
+	// ForwardBackoff is the delay before the first retry, doubling on every retry after
+	ForwardBackoff time.Duration
+	// ForwardMaxBackoff caps the delay between retries
+	ForwardMaxBackoff time.Duration
+	// ForwardWorkers is the number of workers delivering queued payloads
+	ForwardWorkers int
+	// ForwardPollInterval is how often an idle worker checks the queue
+	ForwardPollInterval time.Duration
+
+	// TransformTimeout bounds how long a webhooks transform can run for,
+	// unless the webhook sets its own transform_timeout_ms
+	TransformTimeout time.Duration
+	// TransformMaxOutput caps the size in bytes of a transformed payload,
+	// unless the webhook sets its own transform_max_output_bytes
+	TransformMaxOutput int
+	// TransformTimeoutLimit and TransformMaxOutputLimit are the largest
+	// limits a webhook can set for itself, zero is unlimited
+	TransformTimeoutLimit   time.Duration
+	TransformMaxOutputLimit int
 }
 
 func NewConfig(env string) (*Config, error) {
@@ -30,5 +57,97 @@ func NewConfig(env string) (*Config, error) {
 		MinioUseSSL:    os.Getenv("MINIO_USE_SSL") == "true",
 		ServerAddress:  os.Getenv("SERVER_ADDRESS"),
 	}
+
+	if conf.ForwardTimeout, err = getDuration("FORWARD_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if conf.ForwardMaxAttempts, err = getInt("FORWARD_MAX_ATTEMPTS", 5); err != nil {
+		return nil, err
+	}
+	if conf.ForwardBackoff, err = getDuration("FORWARD_BACKOFF", time.Second); err != nil {
+		return nil, err
+	}
+	if conf.ForwardMaxBackoff, err = getDuration("FORWARD_MAX_BACKOFF", time.Minute); err != nil {
+		return nil, err
+	}
+	if conf.ForwardWorkers, err = getInt("FORWARD_WORKERS", 4); err != nil {
+		return nil, err
+	}
+	if conf.ForwardPollInterval, err = getDuration("FORWARD_POLL_INTERVAL", time.Second); err != nil {
+		return nil, err
+	}
+	if conf.TransformTimeout, err = getDuration("TRANSFORM_TIMEOUT", time.Second); err != nil {
+		return nil, err
+	}
+	if conf.TransformMaxOutput, err = getInt("TRANSFORM_MAX_OUTPUT", 1<<20); err != nil {
+		return nil, err
+	}
+	if conf.TransformTimeoutLimit, err = getDuration("TRANSFORM_TIMEOUT_LIMIT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if conf.TransformMaxOutputLimit, err = getInt("TRANSFORM_MAX_OUTPUT_LIMIT", 10<<20); err != nil {
+		return nil, err
+	}
+
+	if err = conf.validate(); err != nil {
+		return nil, err
+	}
+
 	return conf, nil
 }
+
+// validate rejects settings the forwarder can't run with, its workers
+// tick every poll interval and the lease of a delivery is derived from
+// the timeout, so neither can be unbounded
+func (c *Config) validate() error {
+	durations := map[string]time.Duration{
+		"FORWARD_TIMEOUT":       c.ForwardTimeout,
+		"FORWARD_BACKOFF":       c.ForwardBackoff,
+		"FORWARD_MAX_BACKOFF":   c.ForwardMaxBackoff,
+		"FORWARD_POLL_INTERVAL": c.ForwardPollInterval,
+	}
+	for key, duration := range durations {
+		if duration <= 0 {
+			return errors.Errorf("invalid %s: must be positive", key)
+		}
+	}
+
+	counts := map[string]int{
+		"FORWARD_MAX_ATTEMPTS": c.ForwardMaxAttempts,
+		"FORWARD_WORKERS":      c.ForwardWorkers,
+	}
+	for key, count := range counts {
+		if count < 1 {
+			return errors.Errorf("invalid %s: must be at least 1", key)
+		}
+	}
+	return nil
+}
+
+// getDuration parses the environment variable key as a time.Duration,
+// returning fallback when it is unset
+func getDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid %s", key)
+	}
+	return duration, nil
+}
+
+// getInt parses the environment variable key as an int,
+// returning fallback when it is unset
+func getInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid %s", key)
+	}
+	return i, nil
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Config{
+		ForwardTimeout:      10 * time.Second,
+		ForwardMaxAttempts:  5,
+		ForwardBackoff:      time.Second,
+		ForwardMaxBackoff:   time.Minute,
+		ForwardWorkers:      4,
+		ForwardPollInterval: time.Second,
+	}
+
+	tests := []struct {
+		name   string
+		change func(*Config)
+		valid  bool
+	}{
+		{"defaults", func(*Config) {}, true},
+		{"zero poll interval", func(c *Config) { c.ForwardPollInterval = 0 }, false},
+		{"negative poll interval", func(c *Config) { c.ForwardPollInterval = -time.Second }, false},
+		{"zero timeout", func(c *Config) { c.ForwardTimeout = 0 }, false},
+		{"zero backoff", func(c *Config) { c.ForwardBackoff = 0 }, false},
+		{"zero max backoff", func(c *Config) { c.ForwardMaxBackoff = 0 }, false},
+		{"no workers", func(c *Config) { c.ForwardWorkers = 0 }, false},
+		{"no attempts", func(c *Config) { c.ForwardMaxAttempts = 0 }, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := valid
+			test.change(&conf)
+			err := conf.validate()
+			if test.valid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Ayano2000/push/internal/pkg/logger"
+	"github.com/Ayano2000/push/internal/pkg/middleware"
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// errForbidden is returned when the API key of a request can
+// view a webhook but doesn't have the role a change needs
+var errForbidden = errors.New("api key doesn't have the role required")
+
+// authorize checks the API key of the request has role on team. Keys that
+// can't view the team get sql.ErrNoRows, so webhooks of other teams appear
+// not to exist, and keys that can view it without role get errForbidden
+func authorize(ctx context.Context, team, role string) error {
+	apiKey, ok := middleware.APIKeyFromContext(ctx)
+	if !ok {
+		return errors.WithStack(errors.Errorf("failed to retrieve API key from context"))
+	}
+	if !apiKey.Can(team, types.RoleViewer) {
+		return errors.WithStack(sql.ErrNoRows)
+	}
+	if !apiKey.Can(team, role) {
+		return errors.WithStack(errForbidden)
+	}
+	return nil
+}
+
+// authorizeWebhook checks the API key of the request has role on
+// team before fetching the webhook of the team named name
+func (h *Handler) authorizeWebhook(ctx context.Context, team, name, role string) (types.Webhook, error) {
+	if err := authorize(ctx, team, role); err != nil {
+		return types.Webhook{}, err
+	}
+	return h.getWebhook(ctx, team, name)
+}
+
+// writeWebhookError responds to a failed authorizeWebhook, message
+// is used for errors the user can't fix
+func writeWebhookError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, webhookNotFoundErrorMessage, http.StatusNotFound)
+	case errors.Is(err, errForbidden):
+		http.Error(w, forbiddenErrorMessage, http.StatusForbidden)
+	default:
+		logger.GetFromContext(r.Context()).Error().Err(err).Msg("Failed to retrieve webhook from db")
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
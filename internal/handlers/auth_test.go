@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Ayano2000/push/internal/pkg/middleware"
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name   string
+		grants []types.Grant
+		team   string
+		role   string
+		err    error
+	}{
+		{"viewer can view", []types.Grant{{Team: "acme", Role: types.RoleViewer}}, "acme", types.RoleViewer, nil},
+		{"viewer can't edit", []types.Grant{{Team: "acme", Role: types.RoleViewer}}, "acme", types.RoleEditor, errForbidden},
+		{"editor can't administer", []types.Grant{{Team: "acme", Role: types.RoleEditor}}, "acme", types.RoleAdmin, errForbidden},
+		{"admin can edit", []types.Grant{{Team: "acme", Role: types.RoleAdmin}}, "acme", types.RoleEditor, nil},
+		{"other teams are hidden", []types.Grant{{Team: "acme", Role: types.RoleAdmin}}, "globex", types.RoleViewer, sql.ErrNoRows},
+		{"other teams are hidden for every role", []types.Grant{{Team: "acme", Role: types.RoleAdmin}}, "globex", types.RoleAdmin, sql.ErrNoRows},
+		{"all teams", []types.Grant{{Team: types.AllTeams, Role: types.RoleEditor}}, "globex", types.RoleEditor, nil},
+		{"all teams is limited by role", []types.Grant{{Team: types.AllTeams, Role: types.RoleEditor}}, "globex", types.RoleAdmin, errForbidden},
+		{"grants combine", []types.Grant{{Team: types.AllTeams, Role: types.RoleViewer}, {Team: "acme", Role: types.RoleAdmin}}, "acme", types.RoleAdmin, nil},
+		{"no grants", nil, "acme", types.RoleViewer, sql.ErrNoRows},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := middleware.WithAPIKey(context.Background(), types.APIKey{Grants: test.grants})
+			err := authorize(ctx, test.team, test.role)
+			if test.err == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !errors.Is(err, test.err) {
+				t.Errorf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+
+	err := authorize(context.Background(), "acme", types.RoleViewer)
+	if err == nil || errors.Is(err, sql.ErrNoRows) || errors.Is(err, errForbidden) {
+		t.Errorf("expected an error for a request without an API key, got %v", err)
+	}
+}
+
+func TestWriteWebhookError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		code    int
+		message string
+	}{
+		{"not found", errors.WithStack(sql.ErrNoRows), http.StatusNotFound, webhookNotFoundErrorMessage},
+		{"forbidden", errors.WithStack(errForbidden), http.StatusForbidden, forbiddenErrorMessage},
+		{"other", errors.New("connection refused"), http.StatusInternalServerError, getWebhooksErrorMessage},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			writeWebhookError(rr, newTestRequest(t, http.MethodGet, "", nil, nil), test.err, getWebhooksErrorMessage)
+			if rr.Code != test.code {
+				t.Errorf("expected status code %d, got %d", test.code, rr.Code)
+			}
+			if body := strings.TrimSpace(rr.Body.String()); body != test.message {
+				t.Errorf("expected %q, got %q", test.message, body)
+			}
+		})
+	}
+}
+
+// TestHandlers_RequiredRoles checks each handler rejects keys without the
+// role it requires before it touches storage, so no services are needed
+func TestHandlers_RequiredRoles(t *testing.T) {
+	h := &Handler{}
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		role    string
+		body    string
+	}{
+		{"CreateWebhook", h.CreateWebhook, types.RoleEditor, `{"name": "github", "method": "POST", "path": "/github"}`},
+		{"UpdateWebhook", h.UpdateWebhook, types.RoleEditor, `{}`},
+		{"DeleteWebhook", h.DeleteWebhook, types.RoleAdmin, ``},
+		{"GetWebhookContent", h.GetWebhookContent, types.RoleViewer, ``},
+		{"DeleteWebhookContents", h.DeleteWebhookContents, types.RoleEditor, ``},
+		{"GetEvent", h.GetEvent, types.RoleViewer, ``},
+		{"GetWebhookStats", h.GetWebhookStats, types.RoleViewer, ``},
+		{"PreviewTransform", h.PreviewTransform, types.RoleEditor, `{}`},
+		{"GetRevisions", h.GetRevisions, types.RoleViewer, ``},
+		{"GetRevision", h.GetRevision, types.RoleViewer, ``},
+		{"DiffRevisions", h.DiffRevisions, types.RoleViewer, ``},
+		{"RollbackRevision", h.RollbackRevision, types.RoleEditor, ``},
+		{"GetDeliveries", h.GetDeliveries, types.RoleViewer, ``},
+		{"GetDelivery", h.GetDelivery, types.RoleViewer, ``},
+		{"Redeliver", h.Redeliver, types.RoleEditor, ``},
+		{"RotateSigningSecret", h.RotateSigningSecret, types.RoleAdmin, ``},
+	}
+
+	// the grant just below each role, and the response it gets
+	below := map[string]struct {
+		grant types.Grant
+		code  int
+	}{
+		types.RoleViewer: {types.Grant{Team: "globex", Role: types.RoleAdmin}, http.StatusNotFound},
+		types.RoleEditor: {types.Grant{Team: "acme", Role: types.RoleViewer}, http.StatusForbidden},
+		types.RoleAdmin:  {types.Grant{Team: "acme", Role: types.RoleEditor}, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expected := below[test.role]
+			apiKey := types.APIKey{Grants: []types.Grant{expected.grant}}
+
+			rr := httptest.NewRecorder()
+			test.handler(rr, newTestRequest(t, http.MethodPost, test.body, &apiKey, map[string]string{
+				"team": "acme", "name": "github", "id": "b9f5f5c2-1c39-4bb8-a6e8-8d4f5b0b1f4e", "rev": "1", "other": "2",
+			}))
+			if rr.Code != expected.code {
+				t.Errorf("expected status code %d for a %s:%s key, got %d: %s",
+					expected.code, expected.grant.Team, expected.grant.Role, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	// creating a webhook in a team the key can't view is answered like the other handlers
+	apiKey := types.APIKey{Grants: []types.Grant{{Team: "globex", Role: types.RoleAdmin}}}
+	rr := httptest.NewRecorder()
+	h.CreateWebhook(rr, newTestRequest(t, http.MethodPost, tests[0].body, &apiKey, map[string]string{"team": "acme"}))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status code %d creating a webhook in an unviewable team, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+// newTestRequest returns a request as the router would pass it to
+// a handler, authenticated with apiKey if it is set
+func newTestRequest(t *testing.T, method, body string, apiKey *types.APIKey, params map[string]string) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest(method, "/", strings.NewReader(body))
+
+	log := zerolog.Nop()
+	ctx := context.WithValue(r.Context(), types.LoggerContextKey("logger"), &log)
+	ctx = context.WithValue(ctx, muxContextKey, testRegistrar{})
+	if params != nil {
+		ctx = context.WithValue(ctx, urlParamContextKey, params)
+	}
+	if apiKey != nil {
+		ctx = middleware.WithAPIKey(ctx, *apiKey)
+	}
+	return r.WithContext(ctx)
+}
+
+// testRegistrar is a types.WebhookRegistrar which does nothing
+type testRegistrar struct{}
+
+func (testRegistrar) RegisterWebhook(types.Webhook)               {}
+func (testRegistrar) ReplaceWebhook(types.Webhook, types.Webhook) {}
+func (testRegistrar) UnregisterWebhook(types.Webhook)             {}
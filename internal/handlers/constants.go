@@ -12,9 +12,50 @@ const (
 
 // Error messages
 const createWebhookErrorMessage = "Failed to create webhook"
+const deleteWebhookContentErrorMessage = "Failed to delete webhook content"
+const deleteWebhookErrorMessage = "Failed to delete webhook"
+const deliveryNotFoundErrorMessage = "Delivery not found"
+const eventNotFoundErrorMessage = "Event not found"
+const forbiddenErrorMessage = "The API key doesn't have the role required on the webhooks team"
+const getDeliveriesErrorMessage = "Failed to fetch deliveries"
+const getEventErrorMessage = "Failed to fetch event"
+const getRevisionsErrorMessage = "Failed to fetch webhook revisions"
 const getWebhookContentErrorMessage = "Failed to fetch webhook content"
+const getWebhookStatsErrorMessage = "Failed to fetch webhook stats"
 const getWebhooksErrorMessage = "Failed to fetch webhooks"
-const invalidJQFilterErrorMessage = "JQ filter is invalid"
+const invalidAcceptPredicateErrorMessage = "accept_predicate is invalid for the transform_engine"
+const invalidCursorErrorMessage = "cursor must be the next_cursor of a previous page"
+const invalidDeliveryStatusErrorMessage = "status must be one of 'pending', 'in_progress', 'succeeded' or 'failed'"
+const invalidDestinationErrorMessage = "destinations must each have an http(s) url, a valid predicate and a payload of 'pre' or 'post'"
+const invalidForwardPayloadErrorMessage = "forward_payload must be one of 'pre' or 'post'"
+const invalidForwardToErrorMessage = "forward_to must be an http(s) url"
+const invalidGracePeriodErrorMessage = "grace_period_seconds can't be negative"
+const invalidJQFilterErrorMessage = "jq_filter is invalid for the transform_engine"
+const invalidLimitErrorMessage = "limit must be a positive integer"
+const invalidMethodErrorMessage = "method must be an HTTP method, e.g. POST"
+const invalidNameErrorMessage = "name must be lowercase letters, digits and hyphens, and at most 62 characters long together with its team"
+const invalidOutputModeErrorMessage = "output_mode must be one of 'array', 'ndjson' or 'split'"
+const invalidPathErrorMessage = "path must be a clean path beginning with '/', without whitespace, '{', '}', '?' or '#'"
+const invalidPreviewErrorMessage = "preview requires a sample or up to 100 event_ids"
+const invalidSampleRateErrorMessage = "sample_rate must be between 0 and 1"
+const invalidTeamErrorMessage = "team must be lowercase letters, digits and hyphens, and not 'teams' or 'webhooks'"
+const invalidTimeRangeErrorMessage = "since and until must be RFC 3339 timestamps, with since before until"
+const invalidTransformEngineErrorMessage = "transform_engine must be one of 'jq' or 'template'"
+const invalidTransformLimitErrorMessage = "transform_timeout_ms and transform_max_output_bytes can't be negative or exceed the servers limits"
+const invalidTransformRuleErrorMessage = "transform_rules must each have a valid predicate and filter"
+const invalidVerificationErrorMessage = "verification must have a secret and a provider of 'hmac-sha256' (with a header), 'github', 'stripe', 'slack' or 'shopify'"
 const jqTransformErrorMessage = "Failed to process JQ filter on request body"
+const jqTransformLimitErrorMessage = "JQ filter exceeded the webhooks transform limits"
 const minioUploadErrorMessage = "Failed to upload request body to minio"
+const previewTransformErrorMessage = "Failed to preview transform"
+const redeliverErrorMessage = "Failed to redeliver delivery"
 const requestBodyDecodingErrorMessage = "Failed to decode the request body"
+const revisionNotFoundErrorMessage = "Revision not found"
+const rollbackRevisionErrorMessage = "Failed to rollback webhook revision"
+const rotateSigningSecretErrorMessage = "Failed to rotate signing secret"
+const routeTakenErrorMessage = "Another webhook of the team already has this method and path"
+const storeEventErrorMessage = "Failed to store event"
+const unverifiedRequestErrorMessage = "Request signature verification failed"
+const updateWebhookErrorMessage = "Failed to update webhook"
+const webhookExistsErrorMessage = "A webhook with this name already exists in the team"
+const webhookNotFoundErrorMessage = "Webhook not found"
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/Ayano2000/push/internal/pkg/forwarder"
+	"github.com/Ayano2000/push/internal/pkg/logger"
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+// GetDeliveries lists the deliveries made for a webhook, newest first.
+// Supports ?status= to filter, e.g. status=failed for dead lettered
+// deliveries, and ?limit= to cap the number returned
+func (h *Handler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	log := logger.GetFromContext(r.Context())
+
+	params, err := urlParams(r)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve webhook name from context")
+		http.Error(w, getDeliveriesErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", types.DeliveryStatusPending, types.DeliveryStatusInProgress,
+		types.DeliveryStatusSucceeded, types.DeliveryStatusFailed:
+	default:
+		http.Error(w, invalidDeliveryStatusErrorMessage, http.StatusBadRequest)
+		return
+	}
+
+	limit, err := parseLimit(r, defaultDeliveriesLimit, maxDeliveriesLimit)
+	if err != nil {
+		http.Error(w, invalidLimitErrorMessage, http.StatusBadRequest)
+		return
+	}
+
+	webhook, err := h.authorizeWebhook(r.Context(), params["team"], params["name"], types.RoleViewer)
+	if err != nil {
+		writeWebhookError(w, r, err, getDeliveriesErrorMessage)
+		return
+	}
+
+	deliveries, err := h.Services.Forwarder.ListDeliveries(r.Context(), webhook.ID(), status, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list deliveries")
+		http.Error(w, getDeliveriesErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(deliveries); err != nil {
+		log.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// GetDelivery returns a single delivery along with every attempt made for it
+func (h *Handler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	log := logger.GetFromContext(r.Context())
+
+	params, err := urlParams(r)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve url parameters from context")
+		http.Error(w, getDeliveriesErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	webhook, err := h.authorizeWebhook(r.Context(), params["team"], params["name"], types.RoleViewer)
+	if err != nil {
+		writeWebhookError(w, r, err, getDeliveriesErrorMessage)
+		return
+	}
+
+	delivery, err := h.Services.Forwarder.GetDelivery(r.Context(), webhook.ID(), params["id"])
+	if err != nil {
+		if errors.Is(err, forwarder.ErrDeliveryNotFound) {
+			http.Error(w, deliveryNotFoundErrorMessage, http.StatusNotFound)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to retrieve delivery")
+		http.Error(w, getDeliveriesErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(delivery); err != nil {
+		log.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// Redeliver queues a failed delivery to be sent again, responding
+// with the id of the new delivery
+func (h *Handler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	log := logger.GetFromContext(r.Context())
+
+	params, err := urlParams(r)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve url parameters from context")
+		http.Error(w, redeliverErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	webhook, err := h.authorizeWebhook(r.Context(), params["team"], params["name"], types.RoleEditor)
+	if err != nil {
+		writeWebhookError(w, r, err, redeliverErrorMessage)
+		return
+	}
+
+	id, err := h.Services.Forwarder.Redeliver(r.Context(), webhook.ID(), params["id"])
+	if err != nil {
+		switch {
+		case errors.Is(err, forwarder.ErrDeliveryNotFound):
+			http.Error(w, deliveryNotFoundErrorMessage, http.StatusNotFound)
+		case errors.Is(err, forwarder.ErrNotDeadLettered), errors.Is(err, forwarder.ErrAlreadyRedelivered):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Error().Err(err).Msg("Failed to redeliver delivery")
+			http.Error(w, redeliverErrorMessage, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err = json.NewEncoder(w).Encode(map[string]string{"id": id}); err != nil {
+		log.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// defaultSigningSecretGrace is how long a rotated signing secret keeps
+// signing deliveries when the rotation doesn't set a grace period
+const defaultSigningSecretGrace = 24 * time.Hour
+
+// RotateSigningSecret creates a new secret the webhooks deliveries are signed
+// with, responding with the secret. The secrets it replaces keep signing for
+// the grace_period_seconds of the request body, or a day if it isn't set
+func (h *Handler) RotateSigningSecret(w http.ResponseWriter, r *http.Request) {
+	log := logger.GetFromContext(r.Context())
+
+	params, err := urlParams(r)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve webhook name from context")
+		http.Error(w, rotateSigningSecretErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	var rotation types.SigningSecretRotation
+	if err = json.NewDecoder(r.Body).Decode(&rotation); err != nil && !errors.Is(err, io.EOF) {
+		log.Error().Err(err).Msg("Failed to decode request body")
+		http.Error(w, requestBodyDecodingErrorMessage, http.StatusBadRequest)
+		return
+	}
+	if rotation.GracePeriodSeconds < 0 {
+		http.Error(w, invalidGracePeriodErrorMessage, http.StatusBadRequest)
+		return
+	}
+	grace := defaultSigningSecretGrace
+	if rotation.GracePeriodSeconds > 0 {
+		grace = time.Duration(rotation.GracePeriodSeconds) * time.Second
+	}
+
+	webhook, err := h.authorizeWebhook(r.Context(), params["team"], params["name"], types.RoleAdmin)
+	if err != nil {
+		writeWebhookError(w, r, err, rotateSigningSecretErrorMessage)
+		return
+	}
+
+	secret, err := h.Services.Forwarder.RotateSigningSecret(r.Context(), webhook.ID(), grace)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to rotate signing secret")
+		http.Error(w, rotateSigningSecretErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = json.NewEncoder(w).Encode(secret); err != nil {
+		log.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// parseLimit reads ?limit= from the request, returning fallback
+// when it isn't set and capping it at ceiling
+func parseLimit(r *http.Request, fallback, ceiling int) (int, error) {
+	value := r.URL.Query().Get("limit")
+	if value == "" {
+		return fallback, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		return 0, errors.Errorf("invalid limit %q", value)
+	}
+	return min(limit, ceiling), nil
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/Ayano2000/push/internal/pkg/logger"
+	"github.com/Ayano2000/push/internal/pkg/storage"
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// GetEvent returns a single request received by a webhook,
+// with its pre and post transform payloads together
+func (h *Handler) GetEvent(w http.ResponseWriter, r *http.Request) {
+	log := logger.GetFromContext(r.Context())
+
+	params, err := urlParams(r)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve url parameters from context")
+		http.Error(w, getEventErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	webhook, err := h.authorizeWebhook(r.Context(), params["team"], params["name"], types.RoleViewer)
+	if err != nil {
+		writeWebhookError(w, r, err, getEventErrorMessage)
+		return
+	}
+
+	event, err := storage.GetEvent(r.Context(), h.Services.DB, webhook.ID(), params["id"])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, eventNotFoundErrorMessage, http.StatusNotFound)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to retrieve event from db")
+		http.Error(w, getEventErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	if err = h.Services.Minio.ReadPayloads(r.Context(), webhook.ID(), eventPayloads(&event)); err != nil {
+		log.Error().Err(err).Msg("Failed to read event payloads from minio")
+		http.Error(w, getEventErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(event); err != nil {
+		log.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// eventPayloads returns the payloads stored for an event
+func eventPayloads(event *types.Event) []*types.Payload {
+	payloads := make([]*types.Payload, 0, 2)
+	if event.Raw != nil {
+		payloads = append(payloads, event.Raw)
+	}
+	if event.Transformed != nil {
+		payloads = append(payloads, event.Transformed)
+	}
+	return payloads
+}
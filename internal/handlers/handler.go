@@ -3,6 +3,8 @@ package handlers
 import (
 	"github.com/Ayano2000/push/internal/config"
 	"github.com/Ayano2000/push/internal/services"
+	"github.com/pkg/errors"
+	"net/http"
 )
 
 type Handler struct {
@@ -22,3 +24,12 @@ func NewHandler(config *config.Config) (*Handler, error) {
 	}
 	return handler, nil
 }
+
+// urlParams returns the parameters the router matched in the request path
+func urlParams(r *http.Request) (map[string]string, error) {
+	params, ok := r.Context().Value(urlParamContextKey).(map[string]string)
+	if !ok {
+		return nil, errors.WithStack(errors.Errorf("failed to retrieve url parameters from context"))
+	}
+	return params, nil
+}
@@ -1,19 +1,43 @@
 package handlers
 
 import (
+	"context"
 	"github.com/Ayano2000/push/internal/pkg/logger"
+	"github.com/Ayano2000/push/internal/pkg/storage"
 	"github.com/Ayano2000/push/internal/pkg/types"
 	"github.com/Ayano2000/push/pkg/transformer"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
 	"io"
+	"math/rand/v2"
 	"net/http"
+	"time"
 )
 
 // HandleMessage will read and dump the request body in minio: after running it
 // through the jq filter for the endpoint (if one is set), before forwarding it to
-// the endpoints defined forward_to value (if one is set)
+// the endpoints defined forward_to value (if one is set). The request is indexed
+// as an event, which is updated with the outcome once the request is handled.
+// Requests the webhook drops at ingest are only counted
 func (h *Handler) HandleMessage(w http.ResponseWriter, r *http.Request, wh types.Webhook) {
 	log := logger.GetFromContext(r.Context())
 
+	id, err := uuid.NewV7()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to generate event id")
+		http.Error(w, storeEventErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	event := types.Event{
+		ID:              id.String(),
+		ReceivedAt:      time.Now(),
+		ContentType:     r.Header.Get("Content-Type"),
+		TransformStatus: types.TransformStatusPending,
+		ForwardStatus:   types.ForwardStatusNone,
+		Request:         newRequestMetadata(r, wh.RedactHeaders),
+	}
+
 	preTransform, err := io.ReadAll(r.Body)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to read request body")
@@ -21,8 +45,61 @@ func (h *Handler) HandleMessage(w http.ResponseWriter, r *http.Request, wh types
 		return
 	}
 
+	if wh.Verification != nil {
+		if err = wh.Verification.Verify(r.Header, preTransform, event.ReceivedAt); err != nil {
+			log.Warn().Err(err).Str("provider", wh.Verification.Provider).Msg("request failed signature verification")
+			if err = storage.CountDroppedEvent(r.Context(), h.Services.DB, wh.ID(), types.DropReasonUnverified); err != nil {
+				log.Error().Err(err).Msg("failed to count unverified request")
+			}
+			http.Error(w, unverifiedRequestErrorMessage, http.StatusUnauthorized)
+			return
+		}
+	}
+
+	// filters see the redacted headers, as their output is stored
+	input := transformer.Input{
+		Body:        string(preTransform),
+		ContentType: event.ContentType,
+		Headers:     event.Request.Headers,
+		Query:       event.Request.Query,
+	}
+	// bodies without a content type have always been treated as json
+	if input.ContentType == "" {
+		input.ContentType = transformer.JSONContentType
+	}
+
+	engine, err := h.Services.Programs.Transformer(wh.ID(), wh.TransformEngine)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get the webhooks transform engine")
+		http.Error(w, jqTransformErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	// dropped events are counted rather than stored
+	if reason := h.dropReason(r.Context(), wh, engine, input); reason != "" {
+		if err = storage.CountDroppedEvent(r.Context(), h.Services.DB, wh.ID(), reason); err != nil {
+			log.Error().Err(err).Msg("failed to count dropped event")
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err = storage.InsertEvent(r.Context(), h.Services.DB, wh.ID(), event); err != nil {
+		log.Error().Err(err).Msg("failed to store event")
+		http.Error(w, storeEventErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	// once the event exists it is carried through, even if the client
+	// goes away, so it is never left without its payloads or deliveries
+	ctx := context.WithoutCancel(r.Context())
+	defer func() {
+		if err := storage.UpdateEvent(ctx, h.Services.DB, event); err != nil {
+			log.Error().Err(err).Msg("failed to update event")
+		}
+	}()
+
 	if wh.PreservePayload {
-		err = h.Services.Minio.PutObject(r.Context(), wh.Name, string(preTransform))
+		event.Raw, err = h.putPayload(ctx, wh.ID(), event.ID, types.PayloadPreTransform, input.Body, input.ContentType)
 		if err != nil {
 			log.Error().Err(err).Msg("failed to upload object to minio")
 			http.Error(w, minioUploadErrorMessage, http.StatusInternalServerError)
@@ -30,24 +107,212 @@ func (h *Handler) HandleMessage(w http.ResponseWriter, r *http.Request, wh types
 		}
 	}
 
-	postTransform, err := transformer.Transform(r.Context(), string(preTransform), wh.JQFilter)
+	transformed, err := engine.TransformRules(ctx, input, wh.TransformRules, wh.JQFilter, h.transformLimits(wh))
 	if err != nil {
+		event.TransformStatus = types.TransformStatusFailed
+		event.TransformError = err.Error()
+
+		var limitErr *transformer.LimitError
+		if errors.As(err, &limitErr) {
+			log.Warn().Err(err).Msg("JQ transform exceeded its limits")
+			http.Error(w, jqTransformLimitErrorMessage, http.StatusUnprocessableEntity)
+			return
+		}
 		log.Error().Err(err).Msg("failed to process JQ transform")
 		http.Error(w, jqTransformErrorMessage, http.StatusInternalServerError)
 		return
 	}
+	event.TransformStatus = types.TransformStatusSucceeded
+	if transformed.Skipped {
+		event.TransformStatus = types.TransformStatusSkipped
+	}
 
-	err = h.Services.Minio.PutObject(r.Context(), wh.Name, postTransform)
-	if err != nil {
-		log.Error().Err(err).Msg("failed to upload object to minio")
-		http.Error(w, minioUploadErrorMessage, http.StatusInternalServerError)
+	outputs := transformed.Format(wh.OutputMode)
+	if len(outputs) == 0 {
+		event.TransformStatus = types.TransformStatusDropped
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	// todo: request forwarding
+	// in split mode every output after the first is an event of its own,
+	// sharing the request and raw payload of the event received
+	for i, output := range outputs {
+		if i == 0 {
+			err = h.storeOutput(ctx, wh, engine, &event, input, output)
+		} else {
+			err = h.storeSplitOutput(ctx, wh, engine, event, input, output)
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("failed to store transformed payload")
+			http.Error(w, minioUploadErrorMessage, http.StatusInternalServerError)
+			return
+		}
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if _, err = w.Write([]byte(postTransform)); err != nil {
+	response := outputs[0]
+	if len(outputs) > 1 {
+		response = transformed.Format(transformer.OutputNDJSON)[0]
+	}
+	w.Header().Set("Content-Type", response.ContentType)
+	if _, err = w.Write([]byte(response.Body)); err != nil {
 		log.Error().Err(err).Msg("failed to write response")
 	}
 }
+
+// dropReason returns why the event should be dropped before it is stored, or an
+// empty string to keep it. Events are dropped when the webhooks accept predicate
+// doesn't match them, or when they are left out by its sample rate. A predicate
+// which fails to run keeps the event, so a broken predicate doesn't lose events
+func (h *Handler) dropReason(ctx context.Context, wh types.Webhook, engine transformer.Transformer, input transformer.Input) string {
+	accepted, err := engine.Match(ctx, input, wh.AcceptPredicate, h.transformLimits(wh))
+	if err != nil {
+		logger.GetFromContext(ctx).Warn().Err(err).Msg("failed to evaluate accept predicate")
+		return ""
+	}
+	if !accepted {
+		return types.DropReasonRejected
+	}
+
+	if wh.SampleRate > 0 && rand.Float64() >= wh.SampleRate {
+		return types.DropReasonSampled
+	}
+	return ""
+}
+
+// storeOutput stores a transformed payload of the event before forwarding it
+func (h *Handler) storeOutput(ctx context.Context, wh types.Webhook, engine transformer.Transformer, event *types.Event, raw transformer.Input, output transformer.Result) error {
+	var err error
+	event.Transformed, err = h.putPayload(ctx, wh.ID(), event.ID, types.PayloadPostTransform, output.Body, output.ContentType)
+	if err != nil {
+		return err
+	}
+
+	event.ForwardStatus = h.forward(ctx, wh, engine, event.ID, raw, output)
+	return nil
+}
+
+// storeSplitOutput indexes a further output of a split transform
+// as a new event, copying the request of event, and stores it
+func (h *Handler) storeSplitOutput(ctx context.Context, wh types.Webhook, engine transformer.Transformer, event types.Event, raw transformer.Input, output transformer.Result) error {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	event.ID = id.String()
+	event.Transformed = nil
+	event.ForwardStatus = types.ForwardStatusNone
+
+	if err = storage.InsertEvent(ctx, h.Services.DB, wh.ID(), event); err != nil {
+		return err
+	}
+	err = h.storeOutput(ctx, wh, engine, &event, raw, output)
+	if updateErr := storage.UpdateEvent(context.WithoutCancel(ctx), h.Services.DB, event); updateErr != nil && err == nil {
+		err = updateErr
+	}
+	return err
+}
+
+// putPayload stores a copy of an events body, returning the
+// payload recorded for it in the events index
+func (h *Handler) putPayload(ctx context.Context, webhookID, eventID, kind, body, contentType string) (*types.Payload, error) {
+	key, err := h.Services.Minio.PutObject(ctx, webhookID, eventID, types.Payload{
+		Kind:        kind,
+		ContentType: contentType,
+		Body:        body,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &types.Payload{Key: key, Size: int64(len(body)), Kind: kind, ContentType: contentType}, nil
+}
+
+// transformLimits returns the limits of the webhooks transform,
+// using the configured defaults for those the webhook doesn't set
+func (h *Handler) transformLimits(wh types.Webhook) transformer.Limits {
+	limits := transformer.Limits{
+		Timeout:   h.Services.Config.TransformTimeout,
+		MaxOutput: h.Services.Config.TransformMaxOutput,
+	}
+	if wh.TransformTimeoutMS > 0 {
+		limits.Timeout = time.Duration(wh.TransformTimeoutMS) * time.Millisecond
+	}
+	if wh.TransformMaxOutputBytes > 0 {
+		limits.MaxOutput = wh.TransformMaxOutputBytes
+	}
+
+	// webhooks stored before the maximums were lowered are held to them too
+	maxLimits := h.maxTransformLimits()
+	if maxLimits.Timeout > 0 && (limits.Timeout <= 0 || limits.Timeout > maxLimits.Timeout) {
+		limits.Timeout = maxLimits.Timeout
+	}
+	if maxLimits.MaxOutput > 0 && (limits.MaxOutput <= 0 || limits.MaxOutput > maxLimits.MaxOutput) {
+		limits.MaxOutput = maxLimits.MaxOutput
+	}
+	return limits
+}
+
+// maxTransformLimits returns the largest transform limits a webhook can set
+func (h *Handler) maxTransformLimits() transformer.Limits {
+	return transformer.Limits{
+		Timeout:   h.Services.Config.TransformTimeoutLimit,
+		MaxOutput: h.Services.Config.TransformMaxOutputLimit,
+	}
+}
+
+// forward queues a delivery of the event to each destination whose predicate
+// matches the raw payload with engine, returning the forward status of the event. The
+// payload has been captured, so failing to queue a delivery is logged rather
+// than failing the request
+func (h *Handler) forward(ctx context.Context, wh types.Webhook, engine transformer.Transformer, eventID string, raw transformer.Input, transformed transformer.Result) string {
+	log := logger.GetFromContext(ctx)
+
+	limits := h.transformLimits(wh)
+	status := types.ForwardStatusNone
+	for _, destination := range wh.ForwardDestinations() {
+		matched, err := engine.Match(ctx, raw, destination.Predicate, limits)
+		if err != nil {
+			log.Error().Err(err).Str("target", destination.URL).Msg("failed to evaluate destination predicate")
+			status = types.ForwardStatusFailed
+			continue
+		}
+		if !matched {
+			continue
+		}
+
+		contentType, payload := transformed.ContentType, transformed.Body
+		if destination.Payload == types.PayloadPreTransform {
+			contentType, payload = raw.ContentType, raw.Body
+		}
+		err = h.Services.Forwarder.Enqueue(ctx, wh.ID(), eventID, destination.URL, contentType, payload)
+		if err != nil {
+			log.Error().Err(err).Str("target", destination.URL).Msg("failed to queue request forwarding")
+			status = types.ForwardStatusFailed
+			continue
+		}
+		if status == types.ForwardStatusNone {
+			status = types.ForwardStatusQueued
+		}
+	}
+	return status
+}
+
+// newRequestMetadata captures everything about the request other than its body,
+// the values of the headers listed in redact are replaced with types.RedactedValue
+func newRequestMetadata(r *http.Request, redact []string) types.RequestMetadata {
+	headers := r.Header.Clone()
+	for _, name := range redact {
+		if values, ok := headers[http.CanonicalHeaderKey(name)]; ok {
+			for i := range values {
+				values[i] = types.RedactedValue
+			}
+		}
+	}
+
+	return types.RequestMetadata{
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Query:      r.URL.Query(),
+		Headers:    headers,
+		RemoteAddr: r.RemoteAddr,
+	}
+}
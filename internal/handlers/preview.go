@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/Ayano2000/push/internal/pkg/logger"
+	"github.com/Ayano2000/push/internal/pkg/storage"
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/Ayano2000/push/pkg/transformer"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// maxPreviewEvents is the number of captured events a single preview can run against
+const maxPreviewEvents = 100
+
+// PreviewTransform runs a candidate filter against an inline sample and the raw
+// payloads of already captured events, without changing the webhook or storing
+// anything. The webhooks transform limits apply to every payload
+func (h *Handler) PreviewTransform(w http.ResponseWriter, r *http.Request) {
+	log := logger.GetFromContext(r.Context())
+
+	params, err := urlParams(r)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve webhook name from context")
+		http.Error(w, previewTransformErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	var preview types.TransformPreview
+	if err = json.NewDecoder(r.Body).Decode(&preview); err != nil {
+		log.Error().Err(err).Msg("Failed to decode request body")
+		http.Error(w, requestBodyDecodingErrorMessage, http.StatusBadRequest)
+		return
+	}
+
+	webhook, err := h.authorizeWebhook(r.Context(), params["team"], params["name"], types.RoleEditor)
+	if err != nil {
+		writeWebhookError(w, r, err, previewTransformErrorMessage)
+		return
+	}
+
+	if preview.TransformEngine == "" {
+		preview.TransformEngine = webhook.TransformEngine
+	}
+	if preview.OutputMode == "" {
+		preview.OutputMode = webhook.OutputMode
+	}
+	engine, err := validatePreview(preview)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limits := h.transformLimits(webhook)
+	results := make([]types.TransformPreviewResult, 0, len(preview.EventIDs)+1)
+	if len(preview.Sample) > 0 {
+		sample := transformer.Input{Body: string(preview.Sample), ContentType: transformer.JSONContentType}
+		results = append(results, previewResult(r.Context(), engine, preview, sample, limits))
+	}
+
+	for _, id := range preview.EventIDs {
+		input, err := h.previewInput(r.Context(), webhook.ID(), id)
+		if err != nil {
+			if errors.Is(err, errNoRawPayload) {
+				results = append(results, types.TransformPreviewResult{EventID: id, Error: err.Error()})
+				continue
+			}
+			log.Error().Err(err).Str("event_id", id).Msg("Failed to retrieve raw payload")
+			http.Error(w, previewTransformErrorMessage, http.StatusInternalServerError)
+			return
+		}
+
+		result := previewResult(r.Context(), engine, preview, input, limits)
+		result.EventID = id
+		results = append(results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(results); err != nil {
+		log.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// errNoRawPayload is returned by previewInput for events it can't preview
+var errNoRawPayload = errors.New("event not found or its raw payload wasn't preserved")
+
+// previewInput returns the raw payload of a captured event,
+// along with the headers and query it was received with
+func (h *Handler) previewInput(ctx context.Context, webhookID, eventID string) (transformer.Input, error) {
+	event, err := storage.GetEvent(ctx, h.Services.DB, webhookID, eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return transformer.Input{}, errNoRawPayload
+	}
+	if err != nil {
+		return transformer.Input{}, err
+	}
+	if event.Raw == nil {
+		return transformer.Input{}, errNoRawPayload
+	}
+
+	if err = h.Services.Minio.ReadPayloads(ctx, webhookID, []*types.Payload{event.Raw}); err != nil {
+		return transformer.Input{}, err
+	}
+	return transformer.Input{
+		Body:        event.Raw.Body,
+		ContentType: event.Raw.ContentType,
+		Headers:     event.Request.Headers,
+		Query:       event.Request.Query,
+	}, nil
+}
+
+// previewResult runs the previewed transform against a single payload, formatted in
+// its output mode as it would be stored and forwarded. The candidate is compiled
+// for every payload so it doesn't enter the webhooks cache
+func previewResult(ctx context.Context, engine transformer.Transformer, preview types.TransformPreview, input transformer.Input, limits transformer.Limits) types.TransformPreviewResult {
+	output, err := engine.TransformRules(ctx, input, preview.TransformRules, preview.JQFilter, limits)
+	if err != nil {
+		return types.TransformPreviewResult{Error: err.Error()}
+	}
+	if output.Skipped {
+		return types.TransformPreviewResult{Error: "payloads with content type " + input.ContentType + " can't be transformed"}
+	}
+	payloads := output.Format(preview.OutputMode)
+	switch {
+	case len(payloads) == 0:
+		return types.TransformPreviewResult{Dropped: true}
+	case preview.OutputMode == transformer.OutputSplit:
+		result := types.TransformPreviewResult{ContentType: payloads[0].ContentType, Outputs: make([]string, 0, len(payloads))}
+		for _, payload := range payloads {
+			result.Outputs = append(result.Outputs, payload.Body)
+		}
+		return result
+	default:
+		return types.TransformPreviewResult{Output: payloads[0].Body, ContentType: payloads[0].ContentType}
+	}
+}
+
+// validatePreview checks the candidate transform and that there is something
+// to run it against, returning the engine to run it with
+func validatePreview(preview types.TransformPreview) (transformer.Transformer, error) {
+	engine, err := transformer.Engine(preview.TransformEngine)
+	if err != nil {
+		return nil, validationError(invalidTransformEngineErrorMessage)
+	}
+	if err = engine.Validate(preview.JQFilter); err != nil {
+		return nil, validationError(invalidJQFilterErrorMessage)
+	}
+	if !transformer.IsValidOutputMode(preview.OutputMode) {
+		return nil, validationError(invalidOutputModeErrorMessage)
+	}
+	for _, rule := range preview.TransformRules {
+		if err = transformer.ValidateRule(engine, rule); err != nil {
+			return nil, validationError(invalidTransformRuleErrorMessage)
+		}
+	}
+
+	if len(preview.Sample) == 0 && len(preview.EventIDs) == 0 {
+		return nil, validationError(invalidPreviewErrorMessage)
+	}
+	if len(preview.EventIDs) > maxPreviewEvents {
+		return nil, validationError(invalidPreviewErrorMessage)
+	}
+	return engine, nil
+}
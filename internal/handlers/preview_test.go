@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"context"
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/Ayano2000/push/pkg/transformer"
+	"reflect"
+	"testing"
+)
+
+func TestPreviewResult_OutputMode(t *testing.T) {
+	input := transformer.Input{Body: `{"items": [1, 2]}`, ContentType: transformer.JSONContentType}
+
+	tests := []struct {
+		mode     string
+		filter   string
+		expected types.TransformPreviewResult
+	}{
+		{"", ".items[]", types.TransformPreviewResult{Output: "[1,2]", ContentType: transformer.JSONContentType}},
+		{transformer.OutputArray, ".items[0]", types.TransformPreviewResult{Output: "[1]", ContentType: transformer.JSONContentType}},
+		{transformer.OutputNDJSON, ".items[]", types.TransformPreviewResult{Output: "1\n2\n", ContentType: transformer.NDJSONContentType}},
+		{transformer.OutputSplit, ".items[]", types.TransformPreviewResult{Outputs: []string{"1", "2"}, ContentType: transformer.JSONContentType}},
+		{transformer.OutputSplit, "empty", types.TransformPreviewResult{Dropped: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mode+" "+test.filter, func(t *testing.T) {
+			preview := types.TransformPreview{OutputMode: test.mode, JQFilter: test.filter}
+			result := previewResult(context.Background(), transformer.JQ, preview, input, transformer.Limits{})
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/Ayano2000/push/internal/pkg/logger"
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+)
+
+var errRevisionNotFound = errors.New("revision not found")
+
+// queryRower is implemented by both storage.DatabaseHandler and *sql.Tx
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// insertRevision records the webhooks configuration as its next revision
+func insertRevision(ctx context.Context, db queryRower, webhook types.Webhook) (int, error) {
+	config, err := json.Marshal(webhook)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	var revision int
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO webhook_revisions (webhook_id, revision, config)
+		SELECT $1, COALESCE(MAX(revision), 0) + 1, $2
+		FROM webhook_revisions
+		WHERE webhook_id = $1
+		RETURNING revision`,
+		webhook.ID(), config,
+	).Scan(&revision)
+	return revision, errors.WithStack(err)
+}
+
+// getRevision fetches a single revision of a webhook,
+// returning errRevisionNotFound if it doesn't exist
+func getRevision(ctx context.Context, db queryRower, webhookID, revision string) (types.WebhookRevision, error) {
+	var rev types.WebhookRevision
+	number, err := strconv.Atoi(revision)
+	if err != nil {
+		return rev, errRevisionNotFound
+	}
+
+	var config []byte
+	err = db.QueryRowContext(ctx, `
+		SELECT webhook_id, revision, config, created_at
+		FROM webhook_revisions
+		WHERE webhook_id = $1 AND revision = $2`,
+		webhookID, number,
+	).Scan(&rev.WebhookID, &rev.Revision, &config, &rev.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rev, errRevisionNotFound
+	}
+	if err != nil {
+		return rev, errors.WithStack(err)
+	}
+
+	return rev, errors.WithStack(json.Unmarshal(config, &rev.Config))
+}
+
+// GetRevisions lists every revision of a webhooks configuration, newest first
+func (h *Handler) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	log := logger.GetFromContext(r.Context())
+
+	params, err := urlParams(r)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve webhook name from context")
+		http.Error(w, getRevisionsErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	webhook, err := h.authorizeWebhook(r.Context(), params["team"], params["name"], types.RoleViewer)
+	if err != nil {
+		writeWebhookError(w, r, err, getRevisionsErrorMessage)
+		return
+	}
+
+	rows, err := h.Services.DB.QueryContext(r.Context(), `
+		SELECT webhook_id, revision, config, created_at
+		FROM webhook_revisions
+		WHERE webhook_id = $1
+		ORDER BY revision DESC`,
+		webhook.ID(),
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve revisions from db")
+		http.Error(w, getRevisionsErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	revisions := make([]types.WebhookRevision, 0)
+	for rows.Next() {
+		var rev types.WebhookRevision
+		var config []byte
+		if err = rows.Scan(&rev.WebhookID, &rev.Revision, &config, &rev.CreatedAt); err == nil {
+			err = json.Unmarshal(config, &rev.Config)
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to retrieve revisions from db")
+			http.Error(w, getRevisionsErrorMessage, http.StatusInternalServerError)
+			return
+		}
+		rev.Config = rev.Config.Redacted()
+		revisions = append(revisions, rev)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve revisions from db")
+		http.Error(w, getRevisionsErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	if len(revisions) == 0 {
+		http.Error(w, webhookNotFoundErrorMessage, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(revisions); err != nil {
+		log.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// GetRevision returns a single revision of a webhooks configuration
+func (h *Handler) GetRevision(w http.ResponseWriter, r *http.Request) {
+	log := logger.GetFromContext(r.Context())
+
+	params, err := urlParams(r)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve url parameters from context")
+		http.Error(w, getRevisionsErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	webhook, err := h.authorizeWebhook(r.Context(), params["team"], params["name"], types.RoleViewer)
+	if err != nil {
+		writeWebhookError(w, r, err, getRevisionsErrorMessage)
+		return
+	}
+
+	rev, err := getRevision(r.Context(), h.Services.DB, webhook.ID(), params["rev"])
+	if err != nil {
+		writeRevisionError(w, r, err)
+		return
+	}
+
+	rev.Config = rev.Config.Redacted()
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(rev); err != nil {
+		log.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// DiffRevisions returns the fields changed between two revisions of a webhook
+func (h *Handler) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	log := logger.GetFromContext(r.Context())
+
+	params, err := urlParams(r)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve url parameters from context")
+		http.Error(w, getRevisionsErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	webhook, err := h.authorizeWebhook(r.Context(), params["team"], params["name"], types.RoleViewer)
+	if err != nil {
+		writeWebhookError(w, r, err, getRevisionsErrorMessage)
+		return
+	}
+
+	from, err := getRevision(r.Context(), h.Services.DB, webhook.ID(), params["rev"])
+	if err != nil {
+		writeRevisionError(w, r, err)
+		return
+	}
+
+	to, err := getRevision(r.Context(), h.Services.DB, webhook.ID(), params["other"])
+	if err != nil {
+		writeRevisionError(w, r, err)
+		return
+	}
+
+	changes, err := types.DiffWebhooks(from.Config.Redacted(), to.Config.Redacted())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to diff revisions")
+		http.Error(w, getRevisionsErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(changes); err != nil {
+		log.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// RollbackRevision restores the configuration of a prior revision, which is
+// recorded as a new revision, and re-registers the webhooks route
+func (h *Handler) RollbackRevision(w http.ResponseWriter, r *http.Request) {
+	log := logger.GetFromContext(r.Context())
+
+	params, err := urlParams(r)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve url parameters from context")
+		http.Error(w, rollbackRevisionErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	registrar, ok := r.Context().Value(muxContextKey).(types.WebhookRegistrar)
+	if !ok {
+		err = errors.WithStack(errors.Errorf("failed to retrieve WebhookRegistrar from context"))
+		log.Error().Err(err).Msg("Failed to retrieve WebhookRegistrar from context")
+		http.Error(w, rollbackRevisionErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := h.changeWebhook(r.Context(), registrar, params["team"], params["name"], func(current types.Webhook) (types.Webhook, error) {
+		rev, err := getRevision(r.Context(), h.Services.DB, current.ID(), params["rev"])
+		return rev.Config, err
+	})
+	if err != nil {
+		writeChangeWebhookError(w, r, err, rollbackRevisionErrorMessage)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(updated.Redacted()); err != nil {
+		log.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+func writeRevisionError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errRevisionNotFound) {
+		http.Error(w, revisionNotFoundErrorMessage, http.StatusNotFound)
+		return
+	}
+	logger.GetFromContext(r.Context()).Error().Err(err).Msg("Failed to retrieve revision from db")
+	http.Error(w, getRevisionsErrorMessage, http.StatusInternalServerError)
+}
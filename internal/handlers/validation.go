@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/Ayano2000/push/pkg/transformer"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// namePattern matches the team and name of a webhook, which are joined
+// by a dot as its ID so the ID is a valid bucket name
+var namePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// maxIDLength is the longest bucket name allowed
+const maxIDLength = 63
+
+// reservedTeams can't own webhooks, as the routes of their
+// webhooks would be prefixed with a management route
+var reservedTeams = map[string]bool{"teams": true, "webhooks": true}
+
+// validationError is returned for invalid user supplied configuration,
+// its message is safe to respond with
+type validationError string
+
+func (e validationError) Error() string {
+	return string(e)
+}
+
+// validateWebhook checks the configuration of a webhook before it is
+// created or updated, filling in defaults for optional fields. The
+// transform limits of the webhook can't exceed maxLimits
+func validateWebhook(webhook *types.Webhook, maxLimits transformer.Limits) error {
+	if !namePattern.MatchString(webhook.Team) || reservedTeams[webhook.Team] {
+		return validationError(invalidTeamErrorMessage)
+	}
+	if !namePattern.MatchString(webhook.Name) || len(webhook.ID()) > maxIDLength {
+		return validationError(invalidNameErrorMessage)
+	}
+
+	webhook.Method = strings.ToUpper(webhook.Method)
+	if !isToken(webhook.Method) {
+		return validationError(invalidMethodErrorMessage)
+	}
+
+	if !isRoutePath(webhook.Path) {
+		return validationError(invalidPathErrorMessage)
+	}
+
+	if webhook.Verification != nil && webhook.Verification.Provider == "" {
+		webhook.Verification = nil
+	}
+	if webhook.Verification != nil {
+		if err := webhook.Verification.Validate(); err != nil {
+			return validationError(invalidVerificationErrorMessage)
+		}
+	}
+
+	if webhook.ForwardTo != "" && !isHTTPURL(webhook.ForwardTo) {
+		return validationError(invalidForwardToErrorMessage)
+	}
+
+	switch webhook.ForwardPayload {
+	case "":
+		webhook.ForwardPayload = types.PayloadPostTransform
+	case types.PayloadPreTransform, types.PayloadPostTransform:
+	default:
+		return validationError(invalidForwardPayloadErrorMessage)
+	}
+
+	if webhook.TransformEngine == "" {
+		webhook.TransformEngine = transformer.EngineJQ
+	}
+	engine, err := transformer.Engine(webhook.TransformEngine)
+	if err != nil {
+		return validationError(invalidTransformEngineErrorMessage)
+	}
+
+	if err = engine.Validate(webhook.AcceptPredicate); err != nil {
+		return validationError(invalidAcceptPredicateErrorMessage)
+	}
+	if webhook.SampleRate < 0 || webhook.SampleRate > 1 {
+		return validationError(invalidSampleRateErrorMessage)
+	}
+
+	if err = engine.Validate(webhook.JQFilter); err != nil {
+		return validationError(invalidJQFilterErrorMessage)
+	}
+
+	if !transformer.IsValidOutputMode(webhook.OutputMode) {
+		return validationError(invalidOutputModeErrorMessage)
+	}
+
+	if !isWithinLimit(time.Duration(webhook.TransformTimeoutMS)*time.Millisecond, maxLimits.Timeout) ||
+		!isWithinLimit(webhook.TransformMaxOutputBytes, maxLimits.MaxOutput) {
+		return validationError(invalidTransformLimitErrorMessage)
+	}
+
+	if webhook.TransformRules == nil {
+		webhook.TransformRules = make([]transformer.Rule, 0)
+	}
+	for _, rule := range webhook.TransformRules {
+		if err = transformer.ValidateRule(engine, rule); err != nil {
+			return validationError(invalidTransformRuleErrorMessage)
+		}
+	}
+
+	if webhook.Destinations == nil {
+		webhook.Destinations = make([]types.Destination, 0)
+	}
+	for i := range webhook.Destinations {
+		if err = validateDestination(engine, &webhook.Destinations[i], webhook.ForwardPayload); err != nil {
+			return err
+		}
+	}
+
+	redact := make([]string, 0, len(webhook.RedactHeaders))
+	for _, name := range webhook.RedactHeaders {
+		if name != "" {
+			redact = append(redact, http.CanonicalHeaderKey(name))
+		}
+	}
+	webhook.RedactHeaders = redact
+
+	return nil
+}
+
+// isWithinLimit reports whether value is neither negative nor above limit, zero is unlimited
+func isWithinLimit[T int | time.Duration](value, limit T) bool {
+	return value >= 0 && (limit <= 0 || value <= limit)
+}
+
+// validateDestination checks a destination of a webhook, its predicate is
+// compiled with engine and its payload defaults to the webhooks forward_payload
+func validateDestination(engine transformer.Transformer, destination *types.Destination, forwardPayload string) error {
+	if !isHTTPURL(destination.URL) {
+		return validationError(invalidDestinationErrorMessage)
+	}
+
+	if err := engine.Validate(destination.Predicate); err != nil {
+		return validationError(invalidDestinationErrorMessage)
+	}
+
+	switch destination.Payload {
+	case "":
+		destination.Payload = forwardPayload
+	case types.PayloadPreTransform, types.PayloadPostTransform:
+	default:
+		return validationError(invalidDestinationErrorMessage)
+	}
+
+	return nil
+}
+
+// isHTTPURL reports whether value is an absolute http(s) url, which payloads can be forwarded to
+func isHTTPURL(value string) bool {
+	target, err := url.Parse(value)
+	return err == nil && (target.Scheme == "http" || target.Scheme == "https") && target.Host != ""
+}
+
+// isToken reports whether value is an HTTP token, which methods must be (RFC 9110)
+func isToken(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("!#$%&'*+-.^_`|~", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// isRoutePath reports whether value can be the path of a webhooks route, a clean
+// absolute path the router can register without the characters of its patterns
+func isRoutePath(value string) bool {
+	if !strings.HasPrefix(value, "/") || path.Clean(value) != value {
+		return false
+	}
+	for _, r := range value {
+		if unicode.IsSpace(r) || unicode.IsControl(r) || strings.ContainsRune("{}?#", r) {
+			return false
+		}
+	}
+	return true
+}
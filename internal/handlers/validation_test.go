@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/Ayano2000/push/pkg/transformer"
+	"testing"
+	"time"
+)
+
+func TestValidateWebhook_MethodAndPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		err    string
+	}{
+		{name: "valid", method: "post", path: "/github"},
+		{name: "nested path", method: "PUT", path: "/github/push"},
+		{name: "root path", method: "POST", path: "/"},
+		{name: "extension method", method: "PURGE", path: "/cache"},
+		{name: "empty method", method: "", path: "/github", err: invalidMethodErrorMessage},
+		{name: "method with space", method: "POST /x", path: "/github", err: invalidMethodErrorMessage},
+		{name: "method with separator", method: "GET,POST", path: "/github", err: invalidMethodErrorMessage},
+		{name: "relative path", method: "POST", path: "github", err: invalidPathErrorMessage},
+		{name: "path with space", method: "POST", path: "/git hub", err: invalidPathErrorMessage},
+		{name: "path with tab", method: "POST", path: "/github\t", err: invalidPathErrorMessage},
+		{name: "path with braces", method: "POST", path: "/{name}", err: invalidPathErrorMessage},
+		{name: "path with query", method: "POST", path: "/github?a=b", err: invalidPathErrorMessage},
+		{name: "unclean path", method: "POST", path: "/github/../admin", err: invalidPathErrorMessage},
+		{name: "trailing slash", method: "POST", path: "/github/", err: invalidPathErrorMessage},
+		{name: "double slash", method: "POST", path: "//github", err: invalidPathErrorMessage},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			webhook := types.Webhook{Team: "acme", Name: "github", Method: test.method, Path: test.path}
+			err := validateWebhook(&webhook, transformer.Limits{})
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != test.err {
+				t.Errorf("expected %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestValidateWebhook_TransformLimits(t *testing.T) {
+	maxLimits := transformer.Limits{Timeout: 10 * time.Second, MaxOutput: 1 << 20}
+
+	tests := []struct {
+		name      string
+		timeoutMS int
+		maxOutput int
+		maxLimits transformer.Limits
+		valid     bool
+	}{
+		{name: "server defaults", maxLimits: maxLimits, valid: true},
+		{name: "at the limits", timeoutMS: 10000, maxOutput: 1 << 20, maxLimits: maxLimits, valid: true},
+		{name: "negative timeout", timeoutMS: -1, maxLimits: maxLimits},
+		{name: "negative max output", maxOutput: -1, maxLimits: maxLimits},
+		{name: "timeout above the limit", timeoutMS: 10001, maxLimits: maxLimits},
+		{name: "max output above the limit", maxOutput: 1<<20 + 1, maxLimits: maxLimits},
+		{name: "unlimited server", timeoutMS: 86400000, maxOutput: 1 << 30, valid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			webhook := types.Webhook{
+				Team:                    "acme",
+				Name:                    "github",
+				Method:                  "POST",
+				Path:                    "/github",
+				TransformTimeoutMS:      test.timeoutMS,
+				TransformMaxOutputBytes: test.maxOutput,
+			}
+			err := validateWebhook(&webhook, test.maxLimits)
+			if test.valid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !test.valid && (err == nil || err.Error() != invalidTransformLimitErrorMessage) {
+				t.Errorf("expected %q, got %v", invalidTransformLimitErrorMessage, err)
+			}
+		})
+	}
+}
+
+func TestValidateWebhook_ForwardTo(t *testing.T) {
+	tests := []struct {
+		forwardTo string
+		valid     bool
+	}{
+		{forwardTo: "", valid: true},
+		{forwardTo: "https://example.com/hook", valid: true},
+		{forwardTo: "http://localhost:8080", valid: true},
+		{forwardTo: "example.com/hook"},
+		{forwardTo: "ftp://example.com"},
+		{forwardTo: "https://"},
+		{forwardTo: "://example.com"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.forwardTo, func(t *testing.T) {
+			webhook := types.Webhook{Team: "acme", Name: "github", Method: "POST", Path: "/github", ForwardTo: test.forwardTo}
+			err := validateWebhook(&webhook, transformer.Limits{})
+			if test.valid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !test.valid && (err == nil || err.Error() != invalidForwardToErrorMessage) {
+				t.Errorf("expected %q, got %v", invalidForwardToErrorMessage, err)
+			}
+		})
+	}
+}
@@ -1,31 +1,91 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"github.com/Ayano2000/push/internal/pkg/forwarder"
 	"github.com/Ayano2000/push/internal/pkg/logger"
+	"github.com/Ayano2000/push/internal/pkg/middleware"
+	"github.com/Ayano2000/push/internal/pkg/storage"
 	"github.com/Ayano2000/push/internal/pkg/types"
-	"github.com/Ayano2000/push/pkg/transformer"
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"net/http"
+	"time"
+)
+
+const (
+	defaultContentLimit = 100
+	maxContentLimit     = 1000
 )
 
 // CreateWebhook will create a minio Webhook,
 // a database row and update the server to listen for requests
-// made to http://basepath/<webhook_name>
+// made to http://basepath/<team><path>, responding with the
+// secret the webhooks deliveries are signed with
 func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
 	log := logger.GetFromContext(r.Context())
+
+	params, err := urlParams(r)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve team from context")
+		http.Error(w, createWebhookErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	if err = authorize(r.Context(), params["team"], types.RoleEditor); err != nil {
+		writeWebhookError(w, r, err, createWebhookErrorMessage)
+		return
+	}
+
 	var webhook types.Webhook
-	err := json.NewDecoder(r.Body).Decode(&webhook)
+	err = json.NewDecoder(r.Body).Decode(&webhook)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to decode request body")
 		http.Error(w, requestBodyDecodingErrorMessage, http.StatusInternalServerError)
 		return
 	}
+	webhook.Team = params["team"]
 
-	_, err = transformer.IsValidFilter(webhook.JQFilter)
+	if err = validateWebhook(&webhook, h.maxTransformLimits()); err != nil {
+		log.Error().Err(err).Msg("Failed to validate webhook")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.Services.DB.BeginTx(r.Context(), nil)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to validate JQ filter")
-		http.Error(w, invalidJQFilterErrorMessage, http.StatusBadRequest)
+		log.Error().Err(err).Msg("Failed to begin transaction")
+		http.Error(w, createWebhookErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// the row is inserted first so conflicting webhooks never create a bucket
+	if err = storage.InsertWebhook(r.Context(), tx, webhook); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrWebhookExists):
+			http.Error(w, webhookExistsErrorMessage, http.StatusConflict)
+		case errors.Is(err, storage.ErrRouteTaken):
+			http.Error(w, routeTakenErrorMessage, http.StatusConflict)
+		default:
+			log.Error().Err(err).Msg("Failed to create webhook row in psql")
+			http.Error(w, createWebhookErrorMessage, http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if _, err = insertRevision(r.Context(), tx, webhook); err != nil {
+		log.Error().Err(err).Msg("Failed to create webhook revision in psql")
+		http.Error(w, createWebhookErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	secret, err := forwarder.CreateSigningSecret(r.Context(), tx, webhook.ID())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create signing secret in psql")
+		http.Error(w, createWebhookErrorMessage, http.StatusInternalServerError)
 		return
 	}
 
@@ -35,19 +95,12 @@ func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, createWebhookErrorMessage, http.StatusInternalServerError)
 		return
 	}
-	_, err = h.Services.DB.ExecContext(r.Context(), `
-		INSERT INTO webhooks (name, path, method, description, jq_filter, forward_to, preserve_payload) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		webhook.Name,
-		webhook.Path,
-		webhook.Method,
-		webhook.Description,
-		webhook.JQFilter,
-		webhook.ForwardTo,
-		webhook.PreservePayload,
-	)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to create webhook row in psql")
+
+	if err = tx.Commit(); err != nil {
+		log.Error().Err(err).Msg("Failed to commit webhook creation")
+		if err = h.Services.Minio.DeleteBucket(r.Context(), webhook.ID()); err != nil {
+			log.Error().Err(err).Msg("Failed to delete minio bucket")
+		}
 		http.Error(w, createWebhookErrorMessage, http.StatusInternalServerError)
 		return
 	}
@@ -62,40 +115,167 @@ func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	// the signing secret is only returned here and when it is rotated
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(secret); err != nil {
+		log.Error().Err(err).Msg("Failed to encode response")
+	}
 }
 
+// UpdateWebhook will apply a partial update to a webhooks configuration,
+// the route serving the webhook is swapped for one using the new
+// configuration so requests are never dropped while updating
+func (h *Handler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	log := logger.GetFromContext(r.Context())
+
+	params, err := urlParams(r)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve webhook name from context")
+		http.Error(w, updateWebhookErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	var update types.WebhookUpdate
+	if err = json.NewDecoder(r.Body).Decode(&update); err != nil {
+		log.Error().Err(err).Msg("Failed to decode request body")
+		http.Error(w, requestBodyDecodingErrorMessage, http.StatusBadRequest)
+		return
+	}
+
+	registrar, ok := r.Context().Value(muxContextKey).(types.WebhookRegistrar)
+	if !ok {
+		err = errors.WithStack(errors.Errorf("failed to retrieve WebhookRegistrar from context"))
+		log.Error().Err(err).Msg("Failed to retrieve WebhookRegistrar from context")
+		http.Error(w, updateWebhookErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	updated, err := h.changeWebhook(r.Context(), registrar, params["team"], params["name"], func(current types.Webhook) (types.Webhook, error) {
+		return update.Apply(current), nil
+	})
+	if err != nil {
+		writeChangeWebhookError(w, r, err, updateWebhookErrorMessage)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(updated.Redacted()); err != nil {
+		log.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// changeWebhook locks the webhooks row and replaces its configuration with the
+// result of change, recording it as a new revision. The row stays locked until
+// the route has been swapped, so concurrent changes reach the router in order.
+// Changes need the editor role on the webhooks team
+func (h *Handler) changeWebhook(
+	ctx context.Context,
+	registrar types.WebhookRegistrar,
+	team, name string,
+	change func(current types.Webhook) (types.Webhook, error),
+) (types.Webhook, error) {
+	if err := authorize(ctx, team, types.RoleEditor); err != nil {
+		return types.Webhook{}, err
+	}
+
+	tx, err := h.Services.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return types.Webhook{}, errors.WithStack(err)
+	}
+	defer tx.Rollback()
+
+	current, err := storage.ScanWebhook(tx.QueryRowContext(ctx,
+		"SELECT "+storage.WebhookColumns+" FROM webhooks WHERE team = $1 AND name = $2 FOR UPDATE",
+		team, name,
+	))
+	if err != nil {
+		return types.Webhook{}, errors.WithStack(err)
+	}
+
+	updated, err := change(current)
+	if err != nil {
+		return types.Webhook{}, err
+	}
+	// the team and name identify the webhooks bucket and revisions
+	updated.Team = current.Team
+	updated.Name = current.Name
+	if err = validateWebhook(&updated, h.maxTransformLimits()); err != nil {
+		return types.Webhook{}, err
+	}
+
+	if err = storage.UpdateWebhook(ctx, tx, updated); err != nil {
+		return types.Webhook{}, err
+	}
+
+	if _, err = insertRevision(ctx, tx, updated); err != nil {
+		return types.Webhook{}, err
+	}
+
+	registrar.ReplaceWebhook(current, updated)
+	if err = tx.Commit(); err != nil {
+		registrar.ReplaceWebhook(updated, current)
+		return types.Webhook{}, errors.WithStack(err)
+	}
+	h.Services.Programs.Invalidate(current.ID())
+
+	return updated, nil
+}
+
+// writeChangeWebhookError responds to a failed changeWebhook, message
+// is used for errors the user can't fix
+func writeChangeWebhookError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	var invalid validationError
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, webhookNotFoundErrorMessage, http.StatusNotFound)
+	case errors.Is(err, errForbidden):
+		http.Error(w, forbiddenErrorMessage, http.StatusForbidden)
+	case errors.Is(err, storage.ErrRouteTaken):
+		http.Error(w, routeTakenErrorMessage, http.StatusConflict)
+	case errors.Is(err, errRevisionNotFound):
+		http.Error(w, revisionNotFoundErrorMessage, http.StatusNotFound)
+	case errors.As(err, &invalid):
+		logger.GetFromContext(r.Context()).Error().Err(err).Msg("Failed to validate webhook")
+		http.Error(w, invalid.Error(), http.StatusBadRequest)
+	default:
+		logger.GetFromContext(r.Context()).Error().Err(err).Msg("Failed to change webhook")
+		http.Error(w, message, http.StatusInternalServerError)
+	}
+}
+
+// GetWebhooks lists the webhooks of the teams the API key can view,
+// only those of the team in the path when listing a single team
 func (h *Handler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
 	log := logger.GetFromContext(r.Context())
+	apiKey, _ := middleware.APIKeyFromContext(r.Context())
+	// GET /webhooks has no url parameters, listing every team
+	params, _ := r.Context().Value(urlParamContextKey).(map[string]string)
 
-	rows, err := h.Services.DB.QueryContext(r.Context(), `SELECT * FROM webhooks`)
-	defer rows.Close()
+	rows, err := h.Services.DB.QueryContext(r.Context(),
+		"SELECT "+storage.WebhookColumns+" FROM webhooks WHERE $1 = '' OR team = $1 ORDER BY team, name",
+		params["team"],
+	)
 	if err != nil {
 		log.Error().Err(err).Msg("")
 		http.Error(w, getWebhooksErrorMessage, http.StatusInternalServerError)
 		return
 	}
+	defer rows.Close()
 
 	var webhooks []types.Webhook
 	for rows.Next() {
-		var webhook types.Webhook
-		err = rows.Scan(
-			&webhook.Name,
-			&webhook.Path,
-			&webhook.Method,
-			&webhook.Description,
-			&webhook.JQFilter,
-			&webhook.ForwardTo,
-			&webhook.PreservePayload)
+		webhook, err := storage.ScanWebhook(rows)
 		if err != nil {
 			log.Error().Err(err).Msg("")
 			http.Error(w, getWebhooksErrorMessage, http.StatusInternalServerError)
 			return
 		}
-		webhooks = append(webhooks, webhook)
+		if apiKey.Can(webhook.Team, types.RoleViewer) {
+			webhooks = append(webhooks, webhook.Redacted())
+		}
 	}
 
-	if rows.Err() != nil {
+	if err = rows.Err(); err != nil {
 		log.Error().Err(err).Msg("")
 		http.Error(w, getWebhooksErrorMessage, http.StatusInternalServerError)
 		return
@@ -107,71 +287,223 @@ func (h *Handler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetWebhookContent returns a page of the events a webhook has received with
+// their payloads, oldest first. Supports ?limit= and ?cursor= to page through them, and
+// ?since= and ?until= (RFC 3339) to restrict when they were received
 func (h *Handler) GetWebhookContent(w http.ResponseWriter, r *http.Request) {
 	log := logger.GetFromContext(r.Context())
 
-	params, ok := r.Context().Value(urlParamContextKey).(map[string]string)
-	if !ok {
-		err := errors.WithStack(
-			errors.Errorf("failed to retrieve webhook name from context"))
+	params, err := urlParams(r)
+	if err != nil {
 		log.Error().Err(err).Msg("Failed to retrieve webhook name from context")
 		http.Error(w, getWebhookContentErrorMessage, http.StatusInternalServerError)
 		return
 	}
 
-	rows, err := h.Services.DB.QueryContext(r.Context(),
-		`SELECT * FROM webhooks WHERE name = $1 limit 1`,
-		params["name"],
-	)
+	query, err := parseEventQuery(r)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to retrieve webhook from db")
-		http.Error(w, getWebhookContentErrorMessage, http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer rows.Close()
 
-	var webhooks []types.Webhook
-	for rows.Next() {
-		var webhook types.Webhook
-		err = rows.Scan(
-			&webhook.Name,
-			&webhook.Path,
-			&webhook.Method,
-			&webhook.Description,
-			&webhook.JQFilter,
-			&webhook.ForwardTo,
-			&webhook.PreservePayload)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to retrieve webhook from db")
-			http.Error(w, getWebhookContentErrorMessage, http.StatusInternalServerError)
-			return
-		}
-		webhooks = append(webhooks, webhook)
+	webhook, err := h.authorizeWebhook(r.Context(), params["team"], params["name"], types.RoleViewer)
+	if err != nil {
+		writeWebhookError(w, r, err, getWebhookContentErrorMessage)
+		return
 	}
 
-	if rows.Err() != nil {
-		log.Error().Err(err).Msg("Failed to retrieve webhook from db")
+	page, err := storage.ListEvents(r.Context(), h.Services.DB, webhook.ID(), query)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to list events from db")
 		http.Error(w, getWebhookContentErrorMessage, http.StatusInternalServerError)
 		return
 	}
 
-	content, err := h.Services.Minio.GetObjects(r.Context(), webhooks[0].Name)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to list objects from minio")
+	payloads := make([]*types.Payload, 0, 2*len(page.Items))
+	for i := range page.Items {
+		payloads = append(payloads, eventPayloads(&page.Items[i])...)
+	}
+	if err = h.Services.Minio.ReadPayloads(r.Context(), webhook.ID(), payloads); err != nil {
+		log.Error().Err(err).Msg("Failed to read payloads from minio")
 		http.Error(w, getWebhookContentErrorMessage, http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err = json.NewEncoder(w).Encode(content); err != nil {
+	if err = json.NewEncoder(w).Encode(page); err != nil {
 		log.Error().Err(err).Msg("Failed to encode response")
 	}
 }
 
+// GetWebhookStats returns the number of events a webhook has stored,
+// and the number it has dropped before storing them by reason
+func (h *Handler) GetWebhookStats(w http.ResponseWriter, r *http.Request) {
+	log := logger.GetFromContext(r.Context())
+
+	params, err := urlParams(r)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve webhook name from context")
+		http.Error(w, getWebhookStatsErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	webhook, err := h.authorizeWebhook(r.Context(), params["team"], params["name"], types.RoleViewer)
+	if err != nil {
+		writeWebhookError(w, r, err, getWebhookStatsErrorMessage)
+		return
+	}
+
+	stats, err := storage.GetWebhookStats(r.Context(), h.Services.DB, webhook.ID())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve webhook stats from db")
+		http.Error(w, getWebhookStatsErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(stats); err != nil {
+		log.Error().Err(err).Msg("Failed to encode response")
+	}
+}
+
+// parseEventQuery reads the paging and time range parameters of a content request
+func parseEventQuery(r *http.Request) (types.EventQuery, error) {
+	var query types.EventQuery
+	var err error
+
+	if query.Limit, err = parseLimit(r, defaultContentLimit, maxContentLimit); err != nil {
+		return query, validationError(invalidLimitErrorMessage)
+	}
+	if query.Cursor = r.URL.Query().Get("cursor"); query.Cursor != "" {
+		if _, err = uuid.Parse(query.Cursor); err != nil {
+			return query, validationError(invalidCursorErrorMessage)
+		}
+	}
+
+	if since := r.URL.Query().Get("since"); since != "" {
+		if query.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return query, validationError(invalidTimeRangeErrorMessage)
+		}
+	}
+	if until := r.URL.Query().Get("until"); until != "" {
+		if query.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			return query, validationError(invalidTimeRangeErrorMessage)
+		}
+	}
+	if !query.Since.IsZero() && !query.Until.IsZero() && query.Until.Before(query.Since) {
+		return query, validationError(invalidTimeRangeErrorMessage)
+	}
+
+	return query, nil
+}
+
+// getWebhook fetches a single webhook of a team by name,
+// returning sql.ErrNoRows if it doesn't exist
+func (h *Handler) getWebhook(ctx context.Context, team, name string) (types.Webhook, error) {
+	webhook, err := storage.ScanWebhook(h.Services.DB.QueryRowContext(ctx,
+		"SELECT "+storage.WebhookColumns+" FROM webhooks WHERE team = $1 AND name = $2",
+		team, name,
+	))
+	if err != nil {
+		return webhook, errors.WithStack(err)
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook will remove the webhooks database row, its minio bucket
+// and everything in it, and stop the server listening on its path
 func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
-	// todo
+	log := logger.GetFromContext(r.Context())
+
+	params, err := urlParams(r)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve webhook name from context")
+		http.Error(w, deleteWebhookErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	registrar, ok := r.Context().Value(muxContextKey).(types.WebhookRegistrar)
+	if !ok {
+		err = errors.WithStack(errors.Errorf("failed to retrieve WebhookRegistrar from context"))
+		log.Error().Err(err).Msg("Failed to retrieve WebhookRegistrar from context")
+		http.Error(w, deleteWebhookErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	webhook, err := h.authorizeWebhook(r.Context(), params["team"], params["name"], types.RoleAdmin)
+	if err != nil {
+		writeWebhookError(w, r, err, deleteWebhookErrorMessage)
+		return
+	}
+
+	tx, err := h.Services.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to begin transaction")
+		http.Error(w, deleteWebhookErrorMessage, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// deliveries and their attempts are removed along with the row
+	_, err = tx.ExecContext(r.Context(), `DELETE FROM webhooks WHERE id = $1`, webhook.ID())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to delete webhook row in psql")
+		http.Error(w, deleteWebhookErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	// stop accepting requests before the bucket is removed, the bucket is
+	// removed before the row so a failure leaves the webhook to delete again
+	registrar.UnregisterWebhook(webhook)
+	h.Services.Programs.Invalidate(webhook.ID())
+
+	if err = h.Services.Minio.DeleteBucket(r.Context(), webhook.ID()); err != nil {
+		registrar.RegisterWebhook(webhook)
+		log.Error().Err(err).Msg("Failed to delete minio bucket")
+		http.Error(w, deleteWebhookErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		registrar.RegisterWebhook(webhook)
+		log.Error().Err(err).Msg("Failed to commit webhook deletion")
+		http.Error(w, deleteWebhookErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
+// DeleteWebhookContents will remove every payload the webhook has received,
+// the webhook itself is left in place
 func (h *Handler) DeleteWebhookContents(w http.ResponseWriter, r *http.Request) {
-	// todo
+	log := logger.GetFromContext(r.Context())
+
+	params, err := urlParams(r)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to retrieve webhook name from context")
+		http.Error(w, deleteWebhookContentErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	webhook, err := h.authorizeWebhook(r.Context(), params["team"], params["name"], types.RoleEditor)
+	if err != nil {
+		writeWebhookError(w, r, err, deleteWebhookContentErrorMessage)
+		return
+	}
+
+	_, err = h.Services.DB.ExecContext(r.Context(), "DELETE FROM events WHERE webhook_id = $1", webhook.ID())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to delete events from db")
+		http.Error(w, deleteWebhookContentErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	if err = h.Services.Minio.EmptyBucket(r.Context(), webhook.ID()); err != nil {
+		log.Error().Err(err).Msg("Failed to empty minio bucket")
+		http.Error(w, deleteWebhookContentErrorMessage, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
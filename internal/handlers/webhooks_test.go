@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"database/sql"
+	"github.com/Ayano2000/push/internal/pkg/storage"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteChangeWebhookError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		code    int
+		message string
+	}{
+		{"not found", errors.WithStack(sql.ErrNoRows), http.StatusNotFound, webhookNotFoundErrorMessage},
+		{"forbidden", errors.WithStack(errForbidden), http.StatusForbidden, forbiddenErrorMessage},
+		{"route taken", errors.WithStack(storage.ErrRouteTaken), http.StatusConflict, routeTakenErrorMessage},
+		{"revision not found", errRevisionNotFound, http.StatusNotFound, revisionNotFoundErrorMessage},
+		{"invalid", validationError(invalidPathErrorMessage), http.StatusBadRequest, invalidPathErrorMessage},
+		{"other", errors.New("connection refused"), http.StatusInternalServerError, updateWebhookErrorMessage},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			writeChangeWebhookError(rr, newTestRequest(t, http.MethodPatch, "", nil, nil), test.err, updateWebhookErrorMessage)
+			if rr.Code != test.code {
+				t.Errorf("expected status code %d, got %d", test.code, rr.Code)
+			}
+			if body := strings.TrimSpace(rr.Body.String()); body != test.message {
+				t.Errorf("expected %q, got %q", test.message, body)
+			}
+		})
+	}
+}
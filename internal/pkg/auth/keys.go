@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/pkg/errors"
+)
+
+// KeyPrefix starts every API key, so a leaked key is easy to recognise
+const KeyPrefix = "push_"
+
+// NewKey returns a random API key
+func NewKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return KeyPrefix + base64.RawURLEncoding.EncodeToString(key), nil
+}
+
+// HashKey returns the hash an API key is stored as, keys are
+// random so a single SHA-256 keeps them from being recovered
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,205 @@
+package forwarder
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Ayano2000/push/internal/pkg/storage"
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrDeliveryNotFound   = errors.New("delivery not found")
+	ErrNotDeadLettered    = errors.New("delivery has not failed")
+	ErrAlreadyRedelivered = errors.New("delivery has already been redelivered")
+)
+
+const deliveryColumns = `d.id, d.message_id, d.webhook_id, d.target, d.content_type, d.status, d.attempts, d.next_attempt_at, d.redelivery_of, d.created_at,
+	dl.reason, dl.created_at, dl.redelivery_id`
+
+// ListDeliveries returns the most recent deliveries for a webhook, newest
+// first. When status is set only deliveries with that status are returned
+func (f *Forwarder) ListDeliveries(ctx context.Context, webhookID, status string, limit int) ([]types.Delivery, error) {
+	rows, err := f.db.QueryContext(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM deliveries d
+		LEFT JOIN dead_letters dl ON dl.delivery_id = d.id
+		WHERE d.webhook_id = $1 AND ($2 = '' OR d.status = $2)
+		ORDER BY d.created_at DESC
+		LIMIT $3`,
+		webhookID, status, limit,
+	)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]types.Delivery, 0)
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, errors.WithStack(rows.Err())
+}
+
+// GetDelivery returns a single delivery of a webhook along with
+// all the attempts made for it
+func (f *Forwarder) GetDelivery(ctx context.Context, webhookID, id string) (*types.Delivery, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrDeliveryNotFound
+	}
+
+	delivery, err := scanDelivery(f.db.QueryRowContext(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM deliveries d
+		LEFT JOIN dead_letters dl ON dl.delivery_id = d.id
+		WHERE d.webhook_id = $1 AND d.id = $2`,
+		webhookID, id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	rows, err := f.db.QueryContext(ctx, `
+		SELECT attempt, status_code, latency_ms, response_body, error, attempted_at
+		FROM delivery_attempts
+		WHERE delivery_id = $1
+		ORDER BY attempt`,
+		id,
+	)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var attempt types.DeliveryAttempt
+		err = rows.Scan(
+			&attempt.Attempt,
+			&attempt.StatusCode,
+			&attempt.LatencyMS,
+			&attempt.ResponseBody,
+			&attempt.Error,
+			&attempt.AttemptedAt)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		delivery.Attempts = append(delivery.Attempts, attempt)
+	}
+
+	return &delivery, errors.WithStack(rows.Err())
+}
+
+// Redeliver queues a new delivery with the payload and target of a dead
+// lettered delivery, returning the id of the new delivery. Each dead
+// letter can only be redelivered once, if the redelivery also fails it
+// is dead lettered in turn
+func (f *Forwarder) Redeliver(ctx context.Context, webhookID, id string) (string, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return "", ErrDeliveryNotFound
+	}
+
+	tx, err := f.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer tx.Rollback()
+
+	var messageID, target, contentType string
+	var payload []byte
+	var eventID, redeliveryID sql.NullString
+	var deadLettered bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT d.message_id, d.event_id, d.target, d.content_type, d.payload, dl.delivery_id IS NOT NULL, dl.redelivery_id
+		FROM deliveries d
+		LEFT JOIN dead_letters dl ON dl.delivery_id = d.id
+		WHERE d.webhook_id = $1 AND d.id = $2
+		FOR UPDATE OF d`,
+		webhookID, id,
+	).Scan(&messageID, &eventID, &target, &contentType, &payload, &deadLettered, &redeliveryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrDeliveryNotFound
+	}
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if !deadLettered {
+		return "", ErrNotDeadLettered
+	}
+	if redeliveryID.Valid {
+		return "", ErrAlreadyRedelivered
+	}
+
+	newID, err := uuid.NewRandom()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO deliveries (id, message_id, webhook_id, event_id, target, content_type, payload, status, redelivery_of)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		newID, messageID, webhookID, eventID, target, contentType, payload, types.DeliveryStatusPending, id,
+	)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE dead_letters SET redelivery_id = $1 WHERE delivery_id = $2`, newID, id)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	f.notify()
+	return newID.String(), nil
+}
+
+// scanDelivery reads a delivery selected with deliveryColumns
+func scanDelivery(row storage.Scanner) (types.Delivery, error) {
+	var delivery types.Delivery
+	var redeliveryOf, reason, redeliveryID sql.NullString
+	var deadLetteredAt sql.NullTime
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.MessageID,
+		&delivery.WebhookID,
+		&delivery.Target,
+		&delivery.ContentType,
+		&delivery.Status,
+		&delivery.AttemptCount,
+		&delivery.NextAttemptAt,
+		&redeliveryOf,
+		&delivery.CreatedAt,
+		&reason,
+		&deadLetteredAt,
+		&redeliveryID)
+	if err != nil {
+		return delivery, err
+	}
+
+	if redeliveryOf.Valid {
+		delivery.RedeliveryOf = &redeliveryOf.String
+	}
+	if reason.Valid {
+		delivery.DeadLetter = &types.DeadLetter{
+			Reason:    reason.String,
+			CreatedAt: deadLetteredAt.Time,
+		}
+		if redeliveryID.Valid {
+			delivery.DeadLetter.RedeliveryID = &redeliveryID.String
+		}
+	}
+
+	return delivery, nil
+}
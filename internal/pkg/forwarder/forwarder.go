@@ -0,0 +1,153 @@
+package forwarder
+
+import (
+	"context"
+	"github.com/Ayano2000/push/internal/config"
+	"github.com/Ayano2000/push/internal/pkg/storage"
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxResponseExcerpt is the number of response body bytes kept per attempt
+const maxResponseExcerpt = 1024
+
+type Forwarder struct {
+	client       *http.Client
+	db           storage.DatabaseHandler
+	maxAttempts  int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	workers      int
+	pollInterval time.Duration
+	lease        time.Duration
+	wake         chan struct{}
+}
+
+// NewForwarder creates a Forwarder which records deliveries in db
+func NewForwarder(config *config.Config, db storage.DatabaseHandler) *Forwarder {
+	return &Forwarder{
+		client:       &http.Client{Timeout: config.ForwardTimeout},
+		db:           db,
+		maxAttempts:  max(config.ForwardMaxAttempts, 1),
+		backoff:      config.ForwardBackoff,
+		maxBackoff:   config.ForwardMaxBackoff,
+		workers:      max(config.ForwardWorkers, 1),
+		pollInterval: config.ForwardPollInterval,
+		// a claimed delivery is released to other workers if it
+		// hasn't been completed well after the request timed out
+		lease: 2*config.ForwardTimeout + time.Minute,
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// Enqueue queues the payload of an event for delivery to target, it is sent
+// with contentType. The request is made by a worker started with Start
+func (f *Forwarder) Enqueue(ctx context.Context, webhookID, eventID, target, contentType, payload string) error {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = f.db.ExecContext(ctx, `
+		INSERT INTO deliveries (id, message_id, webhook_id, event_id, target, content_type, payload, status)
+		VALUES ($1, $1, $2, $3, $4, $5, $6, $7)`,
+		id, webhookID, eventID, target, contentType, []byte(payload), types.DeliveryStatusPending,
+	)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	f.notify()
+	return nil
+}
+
+// notify wakes an idle worker rather than waiting for the next poll
+func (f *Forwarder) notify() {
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// send makes a single request to target with the headers in header, the
+// returned attempt has its Error set when no response was received
+func (f *Forwarder) send(ctx context.Context, target, contentType, payload string, header http.Header) types.DeliveryAttempt {
+	attempt := types.DeliveryAttempt{AttemptedAt: time.Now()}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, strings.NewReader(payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := f.client.Do(req)
+	attempt.LatencyMS = time.Since(attempt.AttemptedAt).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	attempt.StatusCode = resp.StatusCode
+	excerpt, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseExcerpt))
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	attempt.ResponseBody = excerptText(excerpt)
+
+	return attempt
+}
+
+func (f *Forwarder) recordAttempt(ctx context.Context, deliveryID string, attempt types.DeliveryAttempt) error {
+	_, err := f.db.ExecContext(ctx, `
+		INSERT INTO delivery_attempts (delivery_id, attempt, status_code, latency_ms, response_body, error, attempted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		deliveryID,
+		attempt.Attempt,
+		attempt.StatusCode,
+		attempt.LatencyMS,
+		attempt.ResponseBody,
+		attempt.Error,
+		attempt.AttemptedAt,
+	)
+	return errors.WithStack(err)
+}
+
+// excerptText makes a response excerpt storable as TEXT, which
+// rejects invalid UTF-8 and NUL bytes
+func excerptText(excerpt []byte) string {
+	return strings.ReplaceAll(strings.ToValidUTF8(string(excerpt), ""), "\x00", "")
+}
+
+// delay returns the backoff before the given attempt, doubling
+// from f.backoff on each retry and capped at f.maxBackoff
+func (f *Forwarder) delay(attempt int) time.Duration {
+	d := f.backoff
+	for i := 2; i < attempt; i++ {
+		d *= 2
+		if d >= f.maxBackoff {
+			return f.maxBackoff
+		}
+	}
+	return min(d, f.maxBackoff)
+}
+
+func isSuccess(statusCode int) bool {
+	return statusCode >= 200 && statusCode < 300
+}
+
+// isRetryable reports whether a response with statusCode may succeed if sent again
+func isRetryable(statusCode int) bool {
+	return statusCode >= 500 ||
+		statusCode == http.StatusRequestTimeout ||
+		statusCode == http.StatusTooManyRequests
+}
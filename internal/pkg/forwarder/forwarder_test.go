@@ -0,0 +1,112 @@
+package forwarder
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestForwarder_SendRecordsResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"foo":"bar"}` {
+			t.Errorf("expected forwarded body, got %s", body)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(strings.Repeat("a", maxResponseExcerpt*2)))
+	}))
+	defer server.Close()
+
+	f := &Forwarder{client: server.Client()}
+	attempt := f.send(context.Background(), server.URL, "application/json", `{"foo":"bar"}`, nil)
+
+	if attempt.Error != "" {
+		t.Errorf("unexpected error: %s", attempt.Error)
+	}
+	if attempt.StatusCode != http.StatusAccepted {
+		t.Errorf("expected status code %d, got %d", http.StatusAccepted, attempt.StatusCode)
+	}
+	if len(attempt.ResponseBody) != maxResponseExcerpt {
+		t.Errorf("expected response excerpt of %d bytes, got %d", maxResponseExcerpt, len(attempt.ResponseBody))
+	}
+}
+
+func TestForwarder_SendRecordsConnectionError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	f := &Forwarder{client: server.Client()}
+	attempt := f.send(context.Background(), server.URL, "application/json", `{}`, nil)
+
+	if attempt.Error == "" {
+		t.Errorf("expected an error sending to a closed server")
+	}
+}
+
+func TestExcerptText(t *testing.T) {
+	expected := map[string]string{
+		"ok":            "ok",
+		"a\x00b":        "ab",
+		"\xff\xfe\x00c": "c",
+	}
+	for excerpt, want := range expected {
+		if got := excerptText([]byte(excerpt)); got != want {
+			t.Errorf("excerpt %q: expected %q, got %q", excerpt, want, got)
+		}
+	}
+}
+
+func TestForwarder_Delay(t *testing.T) {
+	f := &Forwarder{backoff: time.Second, maxBackoff: 5 * time.Second}
+
+	expected := map[int]time.Duration{
+		2: time.Second,
+		3: 2 * time.Second,
+		4: 4 * time.Second,
+		5: 5 * time.Second,
+		9: 5 * time.Second,
+	}
+	for attempt, want := range expected {
+		if got := f.delay(attempt); got != want {
+			t.Errorf("attempt %d: expected delay %s, got %s", attempt, want, got)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	for _, code := range []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusTooManyRequests, http.StatusRequestTimeout} {
+		if !isRetryable(code) {
+			t.Errorf("expected %d to be retryable", code)
+		}
+	}
+	for _, code := range []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnauthorized} {
+		if isRetryable(code) {
+			t.Errorf("expected %d not to be retryable", code)
+		}
+	}
+}
+
+func TestForwarder_SendSetsHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := r.Header.Get("webhook-id"); id != "delivery" {
+			t.Errorf("expected webhook-id header, got %q", id)
+		}
+		if contentType := r.Header.Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("expected content type application/json, got %q", contentType)
+		}
+	}))
+	defer server.Close()
+
+	header := http.Header{}
+	header.Set("webhook-id", "delivery")
+	header.Set("Content-Type", "text/plain")
+
+	f := &Forwarder{client: server.Client()}
+	if attempt := f.send(context.Background(), server.URL, "application/json", `{}`, header); attempt.Error != "" {
+		t.Errorf("unexpected error: %s", attempt.Error)
+	}
+}
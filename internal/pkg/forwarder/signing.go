@@ -0,0 +1,103 @@
+package forwarder
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/Ayano2000/push/pkg/signature"
+	"github.com/pkg/errors"
+	"net/http"
+	"time"
+)
+
+// CreateSigningSecret creates the secret a new webhooks deliveries are
+// signed with, tx is the transaction the webhook is created in
+func CreateSigningSecret(ctx context.Context, tx *sql.Tx, webhookID string) (types.SigningSecret, error) {
+	return insertSigningSecret(ctx, tx, webhookID)
+}
+
+// RotateSigningSecret creates a new secret for signing a webhooks deliveries,
+// the secrets it replaces keep signing alongside it until grace has elapsed
+func (f *Forwarder) RotateSigningSecret(ctx context.Context, webhookID string, grace time.Duration) (types.SigningSecret, error) {
+	tx, err := f.db.BeginTx(ctx, nil)
+	if err != nil {
+		return types.SigningSecret{}, errors.WithStack(err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM signing_secrets WHERE webhook_id = $1 AND expires_at <= now()`, webhookID)
+	if err != nil {
+		return types.SigningSecret{}, errors.WithStack(err)
+	}
+
+	// secrets already expiring sooner aren't extended
+	_, err = tx.ExecContext(ctx, `
+		UPDATE signing_secrets
+		SET expires_at = now() + $2::interval
+		WHERE webhook_id = $1 AND (expires_at IS NULL OR expires_at > now() + $2::interval)`,
+		webhookID, fmt.Sprintf("%d milliseconds", grace.Milliseconds()),
+	)
+	if err != nil {
+		return types.SigningSecret{}, errors.WithStack(err)
+	}
+
+	secret, err := insertSigningSecret(ctx, tx, webhookID)
+	if err != nil {
+		return secret, err
+	}
+	return secret, errors.WithStack(tx.Commit())
+}
+
+// insertSigningSecret stores a new random secret for a webhook
+func insertSigningSecret(ctx context.Context, tx *sql.Tx, webhookID string) (types.SigningSecret, error) {
+	secret := types.SigningSecret{}
+	var err error
+	if secret.Secret, err = signature.NewSecret(); err != nil {
+		return secret, err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO signing_secrets (webhook_id, secret)
+		VALUES ($1, $2)
+		RETURNING created_at`,
+		webhookID, secret.Secret,
+	).Scan(&secret.CreatedAt)
+	return secret, errors.WithStack(err)
+}
+
+// signingSecrets returns the unexpired secrets of a webhook, newest first
+func (f *Forwarder) signingSecrets(ctx context.Context, webhookID string) ([]string, error) {
+	rows, err := f.db.QueryContext(ctx, `
+		SELECT secret
+		FROM signing_secrets
+		WHERE webhook_id = $1 AND (expires_at IS NULL OR expires_at > now())
+		ORDER BY created_at DESC`,
+		webhookID,
+	)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	var secrets []string
+	for rows.Next() {
+		var secret string
+		if err = rows.Scan(&secret); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, errors.WithStack(rows.Err())
+}
+
+// signatureHeader returns the Standard Webhooks headers of an attempt
+// to send delivery, or nil if its webhook has no signing secrets. The
+// message id is the same for every retry and every redelivery
+func (f *Forwarder) signatureHeader(ctx context.Context, delivery types.Delivery, now time.Time) (http.Header, error) {
+	secrets, err := f.signingSecrets(ctx, delivery.WebhookID)
+	if err != nil || len(secrets) == 0 {
+		return nil, err
+	}
+	return signature.Sign(secrets, delivery.MessageID, now, []byte(delivery.Payload))
+}
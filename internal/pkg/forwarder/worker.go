@@ -0,0 +1,172 @@
+package forwarder
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/Ayano2000/push/internal/pkg/logger"
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// Start runs the delivery workers until ctx is cancelled, the returned
+// function blocks until every worker has finished its current delivery
+func (f *Forwarder) Start(ctx context.Context) (wait func()) {
+	var wg sync.WaitGroup
+	for i := 0; i < f.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.work(ctx)
+		}()
+	}
+	return wg.Wait
+}
+
+// work delivers queued payloads until the queue is empty,
+// then sleeps until woken by Enqueue or the poll interval elapses
+func (f *Forwarder) work(ctx context.Context) {
+	log := logger.GetFromContext(ctx)
+	ticker := time.NewTicker(f.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			delivery, err := f.claim(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Error().Err(err).Msg("failed to claim delivery")
+				}
+				break
+			}
+			if delivery == nil {
+				break
+			}
+
+			// the delivery is completed even when shutting down,
+			// so it isn't left claimed until its lease expires
+			if err = f.process(context.WithoutCancel(ctx), *delivery); err != nil {
+				log.Error().Err(err).Str("delivery_id", delivery.ID).Msg("failed to process delivery")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-f.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim locks the next due delivery, skipping rows already locked by
+// other workers. Deliveries whose lease expired, because the worker
+// processing them stopped, are claimed again. Returns nil when the queue is empty
+func (f *Forwarder) claim(ctx context.Context) (*types.Delivery, error) {
+	var delivery types.Delivery
+	var payload []byte
+	err := f.db.QueryRowContext(ctx, `
+		UPDATE deliveries
+		SET status = $1, attempts = attempts + 1, locked_until = now() + $2::interval, updated_at = now()
+		WHERE id = (
+			SELECT id FROM deliveries
+			WHERE (status = $3 AND next_attempt_at <= now())
+			   OR (status = $1 AND locked_until < now())
+			ORDER BY next_attempt_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, message_id, webhook_id, target, content_type, payload, status, attempts, next_attempt_at, created_at`,
+		types.DeliveryStatusInProgress,
+		fmt.Sprintf("%d milliseconds", f.lease.Milliseconds()),
+		types.DeliveryStatusPending,
+	).Scan(
+		&delivery.ID,
+		&delivery.MessageID,
+		&delivery.WebhookID,
+		&delivery.Target,
+		&delivery.ContentType,
+		&payload,
+		&delivery.Status,
+		&delivery.AttemptCount,
+		&delivery.NextAttemptAt,
+		&delivery.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	delivery.Payload = string(payload)
+	return &delivery, nil
+}
+
+// process makes a single attempt for a claimed delivery, then either
+// completes it or puts it back in the queue with a backoff
+func (f *Forwarder) process(ctx context.Context, delivery types.Delivery) error {
+	header, err := f.signatureHeader(ctx, delivery, time.Now())
+	if err != nil {
+		return err
+	}
+
+	result := f.send(ctx, delivery.Target, delivery.ContentType, delivery.Payload, header)
+	result.Attempt = delivery.AttemptCount
+	if err := f.recordAttempt(ctx, delivery.ID, result); err != nil {
+		// the attempt has been made either way, so settle the delivery
+		// rather than leaving it to be claimed and sent again
+		logger.GetFromContext(ctx).Error().Err(err).Str("delivery_id", delivery.ID).Msg("failed to record delivery attempt")
+	}
+
+	switch {
+	case result.Error == "" && isSuccess(result.StatusCode):
+		return f.complete(ctx, delivery.ID, types.DeliveryStatusSucceeded, time.Now())
+	case delivery.AttemptCount < f.maxAttempts && (result.Error != "" || isRetryable(result.StatusCode)):
+		return f.complete(ctx, delivery.ID, types.DeliveryStatusPending, time.Now().Add(f.delay(delivery.AttemptCount+1)))
+	default:
+		reason := result.Error
+		if reason == "" {
+			reason = fmt.Sprintf("received status %d after %d attempts", result.StatusCode, delivery.AttemptCount)
+		}
+		return f.deadLetter(ctx, delivery.ID, reason)
+	}
+}
+
+// complete releases a claimed delivery with the given status
+func (f *Forwarder) complete(ctx context.Context, id, status string, nextAttemptAt time.Time) error {
+	_, err := f.db.ExecContext(ctx, `
+		UPDATE deliveries
+		SET status = $1, next_attempt_at = $2, locked_until = NULL, updated_at = now()
+		WHERE id = $3`,
+		status, nextAttemptAt, id,
+	)
+	return errors.WithStack(err)
+}
+
+// deadLetter fails a claimed delivery and moves it to the dead letter store
+func (f *Forwarder) deadLetter(ctx context.Context, id, reason string) error {
+	tx, err := f.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE deliveries
+		SET status = $1, locked_until = NULL, updated_at = now()
+		WHERE id = $2`,
+		types.DeliveryStatusFailed, id,
+	)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO dead_letters (delivery_id, reason) VALUES ($1, $2)`, id, reason)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(tx.Commit())
+}
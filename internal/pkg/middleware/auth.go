@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Ayano2000/push/internal/pkg/auth"
+	"github.com/Ayano2000/push/internal/pkg/logger"
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/pkg/errors"
+	"net/http"
+	"strings"
+)
+
+const apiKeyContextKey types.APIKeyContextKey = "api_key"
+
+const (
+	unauthorizedErrorMessage   = "A valid API key is required"
+	authenticationErrorMessage = "Failed to authenticate request"
+)
+
+// APIKeyLookup returns the API key stored with hash,
+// or sql.ErrNoRows if there is no such key
+type APIKeyLookup func(ctx context.Context, hash string) (types.APIKey, error)
+
+// RequireAPIKey rejects requests without the bearer token of an API key found
+// by lookup with a 401, the key a request was made with is in its context
+func RequireAPIKey(lookup APIKeyLookup) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || !strings.HasPrefix(key, auth.KeyPrefix) {
+				unauthorized(w)
+				return
+			}
+
+			apiKey, err := lookup(r.Context(), auth.HashKey(key))
+			if errors.Is(err, sql.ErrNoRows) {
+				unauthorized(w)
+				return
+			}
+			if err != nil {
+				logger.GetFromContext(r.Context()).Error().Err(err).Msg("Failed to look up API key")
+				http.Error(w, authenticationErrorMessage, http.StatusInternalServerError)
+				return
+			}
+
+			next(w, r.WithContext(WithAPIKey(r.Context(), apiKey)))
+		}
+	}
+}
+
+// WithAPIKey returns a copy of ctx holding the API key a request was authenticated with
+func WithAPIKey(ctx context.Context, apiKey types.APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey, apiKey)
+}
+
+// APIKeyFromContext returns the API key a request was authenticated with
+func APIKeyFromContext(ctx context.Context) (types.APIKey, bool) {
+	apiKey, ok := ctx.Value(apiKeyContextKey).(types.APIKey)
+	return apiKey, ok
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	http.Error(w, unauthorizedErrorMessage, http.StatusUnauthorized)
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/Ayano2000/push/internal/pkg/auth"
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/rs/zerolog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAPIKey(t *testing.T) {
+	key, err := auth.NewKey()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lookup := func(ctx context.Context, hash string) (types.APIKey, error) {
+		switch hash {
+		case auth.HashKey(key):
+			return types.APIKey{ID: "key", Name: "ci"}, nil
+		case auth.HashKey(auth.KeyPrefix + "broken"):
+			return types.APIKey{}, errors.New("connection refused")
+		default:
+			return types.APIKey{}, sql.ErrNoRows
+		}
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{"valid key", "Bearer " + key, http.StatusOK},
+		{"missing header", "", http.StatusUnauthorized},
+		{"not a bearer token", "Basic " + key, http.StatusUnauthorized},
+		{"unknown key", "Bearer " + auth.KeyPrefix + "unknown", http.StatusUnauthorized},
+		{"not an api key", "Bearer token", http.StatusUnauthorized},
+		{"lookup failure", "Bearer " + auth.KeyPrefix + "broken", http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := RequireAPIKey(lookup)(func(w http.ResponseWriter, r *http.Request) {
+				if apiKey, ok := APIKeyFromContext(r.Context()); !ok || apiKey.Name != "ci" {
+					t.Errorf("expected the api key in the context, got %v", apiKey)
+				}
+			})
+
+			log := zerolog.Nop()
+			r := httptest.NewRequest(http.MethodGet, "/webhooks", nil)
+			r = r.WithContext(context.WithValue(r.Context(), loggerContextKey, &log))
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if w.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, w.Code)
+			}
+		})
+	}
+}
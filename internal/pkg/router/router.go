@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/Ayano2000/push/internal/handlers"
 	"github.com/Ayano2000/push/internal/pkg/middleware"
+	"github.com/Ayano2000/push/internal/pkg/storage"
 	"github.com/Ayano2000/push/internal/pkg/types"
 	"github.com/pkg/errors"
 	"net/http"
@@ -94,6 +95,11 @@ func (dmux *Router) HandleFunc(pattern string, handler http.HandlerFunc) {
 	dmux.mutex.Lock()
 	defer dmux.mutex.Unlock()
 
+	dmux.addRoute(pattern, handler)
+}
+
+// addRoute registers a route, the caller must hold the write lock
+func (dmux *Router) addRoute(pattern string, handler http.HandlerFunc) {
 	parts := strings.Split(pattern, " ")
 	if len(parts) != 2 {
 		panic("invalid pattern: " + pattern)
@@ -116,36 +122,92 @@ func (dmux *Router) HandleFunc(pattern string, handler http.HandlerFunc) {
 
 // RegisterWebhook adds a new webhook route dynamically
 func (dmux *Router) RegisterWebhook(webhook types.Webhook) {
-	pattern := fmt.Sprintf(patternString, webhook.Method, webhook.Path)
-	dmux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+	dmux.HandleFunc(webhookPattern(webhook), dmux.webhookHandler(webhook))
+}
+
+// ReplaceWebhook swaps a webhooks route for one using its updated
+// configuration, both changes are made under a single lock so
+// requests never see the route missing
+func (dmux *Router) ReplaceWebhook(old types.Webhook, updated types.Webhook) {
+	dmux.mutex.Lock()
+	defer dmux.mutex.Unlock()
+
+	dmux.deleteRoute(webhookPattern(old))
+	dmux.addRoute(webhookPattern(updated), dmux.webhookHandler(updated))
+}
+
+func (dmux *Router) webhookHandler(webhook types.Webhook) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		dmux.handler.HandleMessage(w, r, webhook)
-	})
+	}
+}
+
+// webhookPattern is the route of a webhook, its path is prefixed with
+// the team owning it so every team has a namespace of its own
+func webhookPattern(webhook types.Webhook) string {
+	return fmt.Sprintf(patternString, webhook.Method, "/"+webhook.Team+webhook.Path)
+}
+
+// UnregisterWebhook removes a webhook route, requests made
+// to it are responded to with a 404 from then on
+func (dmux *Router) UnregisterWebhook(webhook types.Webhook) {
+	dmux.removeRoute(webhookPattern(webhook))
+}
+
+// removeRoute removes the route registered with pattern, if there is one
+func (dmux *Router) removeRoute(pattern string) {
+	dmux.mutex.Lock()
+	defer dmux.mutex.Unlock()
+
+	dmux.deleteRoute(pattern)
+}
+
+// deleteRoute removes a route, the caller must hold the write lock
+func (dmux *Router) deleteRoute(pattern string) {
+	delete(dmux.staticRoutes, pattern)
+	for i, route := range dmux.dynamicRoutes {
+		if route.pattern == pattern {
+			dmux.dynamicRoutes = append(dmux.dynamicRoutes[:i:i], dmux.dynamicRoutes[i+1:]...)
+			break
+		}
+	}
 }
 
 // ServeHTTP implements the http.Handler interface
 func (dmux *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route, params := dmux.findRoute(r.Method, r.URL.Path)
+	if route == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), muxContextKey, dmux)
+	if params != nil {
+		ctx = context.WithValue(ctx, urlParamContextKey, params)
+	}
+	r = r.WithContext(ctx)
+	dmux.applyMiddleware(route.handler)(w, r)
+}
+
+// findRoute returns the route matching the request, along with the parameters
+// of a dynamic route. The lock is only held while searching, so handlers
+// are free to register and unregister routes
+func (dmux *Router) findRoute(method, path string) (*Route, map[string]string) {
 	dmux.mutex.RLock()
-	dmux.mutex.RUnlock()
+	defer dmux.mutex.RUnlock()
 
-	pattern := fmt.Sprintf(patternString, r.Method, r.URL.Path)
+	pattern := fmt.Sprintf(patternString, method, path)
 	if route, exists := dmux.staticRoutes[pattern]; exists {
-		ctx := context.WithValue(r.Context(), muxContextKey, dmux)
-		r = r.WithContext(ctx)
-		dmux.applyMiddleware(route.handler)(w, r)
-		return
+		return route, nil
 	}
 
 	for _, route := range dmux.dynamicRoutes {
-		if params, ok := matchRoute(route, r.Method, r.URL.Path); ok {
-			ctx := context.WithValue(r.Context(), muxContextKey, dmux)
-			ctx = context.WithValue(ctx, urlParamContextKey, params)
-			r = r.WithContext(ctx)
-			dmux.applyMiddleware(route.handler)(w, r)
-			return
+		if params, ok := matchRoute(route, method, path); ok {
+			return route, params
 		}
 	}
 
-	http.NotFound(w, r)
+	return nil, nil
 }
 
 // Use appends the given functions to middleware
@@ -165,38 +227,48 @@ func (dmux *Router) applyMiddleware(handler http.HandlerFunc) http.HandlerFunc {
 func RegisterRoutes(handler *handlers.Handler) (*Router, error) {
 	dmux := NewDynamicMux(handler)
 
-	// Register static router
-	dmux.HandleFunc("POST /webhooks", handler.CreateWebhook)
-	dmux.HandleFunc("GET /webhooks", handler.GetWebhooks)
-	dmux.HandleFunc("GET /webhooks/{name}/content", handler.GetWebhookContent)
-	dmux.HandleFunc("DELETE /webhooks/{name}", handler.DeleteWebhook)
-	dmux.HandleFunc("DELETE /webhooks/{name}/content", handler.DeleteWebhookContents)
+	// management routes require an API key, webhook routes are public
+	authenticated := middleware.RequireAPIKey(func(ctx context.Context, hash string) (types.APIKey, error) {
+		return storage.GetAPIKey(ctx, handler.Services.DB, hash)
+	})
+
+	// Register static router, webhooks are managed under the team owning them
+	dmux.HandleFunc("GET /webhooks", authenticated(handler.GetWebhooks))
+	dmux.HandleFunc("POST /teams/{team}/webhooks", authenticated(handler.CreateWebhook))
+	dmux.HandleFunc("GET /teams/{team}/webhooks", authenticated(handler.GetWebhooks))
+	dmux.HandleFunc("GET /teams/{team}/webhooks/{name}/content", authenticated(handler.GetWebhookContent))
+	dmux.HandleFunc("PATCH /teams/{team}/webhooks/{name}", authenticated(handler.UpdateWebhook))
+	dmux.HandleFunc("DELETE /teams/{team}/webhooks/{name}", authenticated(handler.DeleteWebhook))
+	dmux.HandleFunc("DELETE /teams/{team}/webhooks/{name}/content", authenticated(handler.DeleteWebhookContents))
+	dmux.HandleFunc("GET /teams/{team}/webhooks/{name}/events/{id}", authenticated(handler.GetEvent))
+	dmux.HandleFunc("GET /teams/{team}/webhooks/{name}/stats", authenticated(handler.GetWebhookStats))
+	dmux.HandleFunc("POST /teams/{team}/webhooks/{name}/transform/preview", authenticated(handler.PreviewTransform))
+	dmux.HandleFunc("GET /teams/{team}/webhooks/{name}/revisions", authenticated(handler.GetRevisions))
+	dmux.HandleFunc("GET /teams/{team}/webhooks/{name}/revisions/{rev}", authenticated(handler.GetRevision))
+	dmux.HandleFunc("GET /teams/{team}/webhooks/{name}/revisions/{rev}/diff/{other}", authenticated(handler.DiffRevisions))
+	dmux.HandleFunc("POST /teams/{team}/webhooks/{name}/revisions/{rev}/rollback", authenticated(handler.RollbackRevision))
+	dmux.HandleFunc("GET /teams/{team}/webhooks/{name}/deliveries", authenticated(handler.GetDeliveries))
+	dmux.HandleFunc("GET /teams/{team}/webhooks/{name}/deliveries/{id}", authenticated(handler.GetDelivery))
+	dmux.HandleFunc("POST /teams/{team}/webhooks/{name}/deliveries/{id}/redeliver", authenticated(handler.Redeliver))
+	dmux.HandleFunc("POST /teams/{team}/webhooks/{name}/signing-secret/rotate", authenticated(handler.RotateSigningSecret))
 
 	// Register existing webhooks
-	rows, err := handler.Services.DB.QueryContext(context.Background(), `SELECT * FROM webhooks`)
-	defer rows.Close()
+	rows, err := handler.Services.DB.QueryContext(context.Background(), "SELECT "+storage.WebhookColumns+" FROM webhooks")
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	defer rows.Close()
 
 	var webhooks []types.Webhook
 	for rows.Next() {
-		var webhook types.Webhook
-		err = rows.Scan(
-			&webhook.Name,
-			&webhook.Path,
-			&webhook.Method,
-			&webhook.Description,
-			&webhook.JQFilter,
-			&webhook.ForwardTo,
-			&webhook.PreservePayload)
+		webhook, err := storage.ScanWebhook(rows)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
 		webhooks = append(webhooks, webhook)
 	}
 
-	if rows.Err() != nil {
+	if err = rows.Err(); err != nil {
 		return nil, errors.WithStack(err)
 	}
 
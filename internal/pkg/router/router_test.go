@@ -2,6 +2,7 @@ package router
 
 import (
 	"github.com/Ayano2000/push/internal/handlers"
+	"github.com/Ayano2000/push/internal/pkg/types"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -87,3 +88,83 @@ func TestRouter_MatchRoute(t *testing.T) {
 		t.Errorf("expected parameter 'name' to be 'asdf', got %s", params["name"])
 	}
 }
+
+func TestRouter_UnregisterWebhook(t *testing.T) {
+	router := NewDynamicMux(&handlers.Handler{})
+	webhook := types.Webhook{Name: "github", Team: "acme", Method: "POST", Path: "/github"}
+	router.HandleFunc("POST /acme/github", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	router.HandleFunc("POST /other", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	router.UnregisterWebhook(webhook)
+
+	req, _ := http.NewRequest("POST", "/acme/github", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	req, _ = http.NewRequest("POST", "/other", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestRouter_RemoveDynamicRoute(t *testing.T) {
+	router := NewDynamicMux(&handlers.Handler{})
+	router.HandleFunc("GET /webhooks/{name}/content", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	router.removeRoute("GET /webhooks/{name}/content")
+
+	req, _ := http.NewRequest("GET", "/webhooks/asdf/content", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestRouter_ReplaceWebhook(t *testing.T) {
+	router := NewDynamicMux(&handlers.Handler{})
+	old := types.Webhook{Name: "github", Team: "acme", Method: "POST", Path: "/github"}
+	updated := types.Webhook{Name: "github", Team: "acme", Method: "PUT", Path: "/github/v2"}
+	router.HandleFunc("POST /acme/github", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	router.ReplaceWebhook(old, updated)
+
+	req, _ := http.NewRequest("POST", "/acme/github", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	if _, exists := router.staticRoutes["PUT /acme/github/v2"]; !exists {
+		t.Errorf("expected route 'PUT /acme/github/v2' to be registered")
+	}
+}
+
+func TestRouter_RegisterWebhookPrefixesTeam(t *testing.T) {
+	router := NewDynamicMux(&handlers.Handler{})
+	router.RegisterWebhook(types.Webhook{Name: "github", Team: "acme", Method: "POST", Path: "/github"})
+	router.RegisterWebhook(types.Webhook{Name: "github", Team: "globex", Method: "POST", Path: "/github"})
+
+	for _, pattern := range []string{"POST /acme/github", "POST /globex/github"} {
+		if _, exists := router.staticRoutes[pattern]; !exists {
+			t.Errorf("expected route '%s' to be registered", pattern)
+		}
+	}
+	if _, exists := router.staticRoutes["POST /github"]; exists {
+		t.Errorf("expected the webhooks path to be prefixed with its team")
+	}
+}
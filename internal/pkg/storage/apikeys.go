@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/pkg/errors"
+)
+
+// InsertAPIKey stores a new API key and its grants by the hash of the key
+func InsertAPIKey(ctx context.Context, db Execer, apiKey types.APIKey, hash string) error {
+	_, err := db.ExecContext(ctx, `
+		WITH key AS (
+			INSERT INTO api_keys (id, name, key_hash)
+			VALUES ($1, $2, $3)
+			RETURNING id
+		)
+		INSERT INTO api_key_grants (api_key_id, team, role)
+		SELECT key.id, g.team, g.role
+		FROM key, jsonb_to_recordset($4::jsonb) AS g(team TEXT, role TEXT)`,
+		apiKey.ID, apiKey.Name, hash, JSON(apiKey.Grants),
+	)
+	return errors.WithStack(err)
+}
+
+// GetAPIKey returns the unrevoked API key stored with hash along with its
+// grants, recording that it was used. Returns sql.ErrNoRows if there is no such key
+func GetAPIKey(ctx context.Context, db DatabaseHandler, hash string) (types.APIKey, error) {
+	var apiKey types.APIKey
+	err := db.QueryRowContext(ctx, `
+		UPDATE api_keys
+		SET last_used_at = now()
+		WHERE key_hash = $1 AND revoked_at IS NULL
+		RETURNING id, name, created_at, (
+			SELECT COALESCE(jsonb_agg(jsonb_build_object('team', g.team, 'role', g.role)), '[]')
+			FROM api_key_grants g
+			WHERE g.api_key_id = api_keys.id
+		)`,
+		hash,
+	).Scan(&apiKey.ID, &apiKey.Name, &apiKey.CreatedAt, JSON(&apiKey.Grants))
+	return apiKey, errors.WithStack(err)
+}
+
+// RevokeAPIKey stops an API key from authenticating, returning
+// sql.ErrNoRows if there is no unrevoked key with the id
+func RevokeAPIKey(ctx context.Context, db Execer, id string) error {
+	result, err := db.ExecContext(ctx, `
+		UPDATE api_keys
+		SET revoked_at = now()
+		WHERE id = $1 AND revoked_at IS NULL`,
+		id,
+	)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if revoked, err := result.RowsAffected(); err != nil || revoked == 0 {
+		return errors.WithStack(sql.ErrNoRows)
+	}
+	return nil
+}
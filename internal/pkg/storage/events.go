@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// eventColumns is the column list matching the scan order of scanEvent, the
+// forward status of a queued event is derived from its deliveries, ignoring
+// failed deliveries which have since been redelivered
+const eventColumns = `e.id, e.received_at, e.content_type, e.method, e.path, e.query, e.headers, e.remote_addr,
+	e.raw_key, e.raw_size, e.transformed_key, e.transformed_size, e.transformed_content_type,
+	e.transform_status, e.transform_error,
+	CASE
+		WHEN e.forward_status <> 'queued' OR ds.total = 0 THEN e.forward_status
+		WHEN ds.failed > 0 THEN 'failed'
+		WHEN ds.succeeded = ds.total THEN 'succeeded'
+		ELSE 'pending'
+	END`
+
+const eventsFrom = `
+	FROM events e
+	LEFT JOIN LATERAL (
+		SELECT count(*) AS total,
+			count(*) FILTER (WHERE d.status = 'failed') AS failed,
+			count(*) FILTER (WHERE d.status = 'succeeded') AS succeeded
+		FROM deliveries d
+		LEFT JOIN dead_letters dl ON dl.delivery_id = d.id
+		WHERE d.event_id = e.id AND dl.redelivery_id IS NULL
+	) ds ON true`
+
+// InsertEvent indexes a request received by a webhook
+func InsertEvent(ctx context.Context, db Execer, webhookID string, event types.Event) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO events (id, webhook_id, received_at, content_type, method, path, query, headers, remote_addr,
+			transform_status, forward_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		event.ID,
+		webhookID,
+		event.ReceivedAt,
+		event.ContentType,
+		event.Request.Method,
+		event.Request.Path,
+		JSON(event.Request.Query),
+		JSON(event.Request.Headers),
+		event.Request.RemoteAddr,
+		event.TransformStatus,
+		event.ForwardStatus,
+	)
+	return errors.WithStack(err)
+}
+
+// UpdateEvent records the payloads stored for an event and
+// the outcome of transforming and forwarding it
+func UpdateEvent(ctx context.Context, db Execer, event types.Event) error {
+	var rawKey, transformedKey, transformedContentType sql.NullString
+	var rawSize, transformedSize sql.NullInt64
+	if event.Raw != nil {
+		rawKey = sql.NullString{String: event.Raw.Key, Valid: true}
+		rawSize = sql.NullInt64{Int64: event.Raw.Size, Valid: true}
+	}
+	if event.Transformed != nil {
+		transformedKey = sql.NullString{String: event.Transformed.Key, Valid: true}
+		transformedSize = sql.NullInt64{Int64: event.Transformed.Size, Valid: true}
+		transformedContentType = sql.NullString{String: event.Transformed.ContentType, Valid: true}
+	}
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE events
+		SET raw_key = $2, raw_size = $3, transformed_key = $4, transformed_size = $5,
+			transformed_content_type = $6, transform_status = $7, transform_error = $8, forward_status = $9
+		WHERE id = $1`,
+		event.ID,
+		rawKey,
+		rawSize,
+		transformedKey,
+		transformedSize,
+		transformedContentType,
+		event.TransformStatus,
+		event.TransformError,
+		event.ForwardStatus,
+	)
+	return errors.WithStack(err)
+}
+
+// ListEvents returns a page of a webhooks events in the order they were
+// received. Event ids are time ordered, so the cursor is the last id returned
+func ListEvents(ctx context.Context, db DatabaseHandler, webhookID string, query types.EventQuery) (types.EventPage, error) {
+	page := types.EventPage{Items: make([]types.Event, 0)}
+
+	var cursor, since, until any
+	if query.Cursor != "" {
+		cursor = query.Cursor
+	}
+	if !query.Since.IsZero() {
+		since = query.Since
+	}
+	if !query.Until.IsZero() {
+		until = query.Until
+	}
+
+	// fetch one more than the limit to know if there is a next page
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+eventColumns+eventsFrom+`
+		WHERE e.webhook_id = $1
+			AND ($2::uuid IS NULL OR e.id > $2::uuid)
+			AND ($3::timestamptz IS NULL OR e.received_at >= $3::timestamptz)
+			AND ($4::timestamptz IS NULL OR e.received_at <= $4::timestamptz)
+		ORDER BY e.id
+		LIMIT $5`,
+		webhookID, cursor, since, until, query.Limit+1,
+	)
+	if err != nil {
+		return page, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return page, errors.WithStack(err)
+		}
+		page.Items = append(page.Items, event)
+	}
+	if err = rows.Err(); err != nil {
+		return page, errors.WithStack(err)
+	}
+
+	if len(page.Items) > query.Limit {
+		page.Items = page.Items[:query.Limit]
+		page.NextCursor = page.Items[query.Limit-1].ID
+	}
+
+	return page, nil
+}
+
+// GetEvent returns a single event of a webhook, returning sql.ErrNoRows if it doesn't exist
+func GetEvent(ctx context.Context, db DatabaseHandler, webhookID, id string) (types.Event, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return types.Event{}, errors.WithStack(sql.ErrNoRows)
+	}
+
+	event, err := scanEvent(db.QueryRowContext(ctx, `
+		SELECT `+eventColumns+eventsFrom+`
+		WHERE e.webhook_id = $1 AND e.id = $2`,
+		webhookID, id,
+	))
+	return event, errors.WithStack(err)
+}
+
+// scanEvent reads an event selected with eventColumns
+func scanEvent(row Scanner) (types.Event, error) {
+	var event types.Event
+	var rawKey, transformedKey, transformedContentType sql.NullString
+	var rawSize, transformedSize sql.NullInt64
+	err := row.Scan(
+		&event.ID,
+		&event.ReceivedAt,
+		&event.ContentType,
+		&event.Request.Method,
+		&event.Request.Path,
+		JSON(&event.Request.Query),
+		JSON(&event.Request.Headers),
+		&event.Request.RemoteAddr,
+		&rawKey,
+		&rawSize,
+		&transformedKey,
+		&transformedSize,
+		&transformedContentType,
+		&event.TransformStatus,
+		&event.TransformError,
+		&event.ForwardStatus)
+	if err != nil {
+		return event, err
+	}
+
+	if rawKey.Valid {
+		event.Raw = &types.Payload{
+			Key:         rawKey.String,
+			Size:        rawSize.Int64,
+			Kind:        types.PayloadPreTransform,
+			ContentType: event.ContentType,
+		}
+	}
+	if transformedKey.Valid {
+		event.Transformed = &types.Payload{
+			Key:         transformedKey.String,
+			Size:        transformedSize.Int64,
+			Kind:        types.PayloadPostTransform,
+			ContentType: transformedContentType.String,
+		}
+	}
+
+	return event, nil
+}
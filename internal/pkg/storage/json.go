@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"github.com/pkg/errors"
+)
+
+// jsonColumn reads and writes a JSON/JSONB column to and from a Go value
+type jsonColumn struct {
+	value any
+}
+
+// JSON wraps value so it can be passed as a query argument to be stored as
+// JSON, or passed as a pointer to Scan to be decoded from a JSON column
+func JSON(value any) interface {
+	driver.Valuer
+	Scan(src any) error
+} {
+	return jsonColumn{value: value}
+}
+
+func (j jsonColumn) Value() (driver.Value, error) {
+	data, err := json.Marshal(j.value)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return string(data), nil
+}
+
+func (j jsonColumn) Scan(src any) error {
+	switch data := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return errors.WithStack(json.Unmarshal(data, j.value))
+	case string:
+		return errors.WithStack(json.Unmarshal([]byte(data), j.value))
+	default:
+		return errors.Errorf("cannot scan %T into a json column", src)
+	}
+}
@@ -2,16 +2,18 @@ package storage
 
 import (
 	"context"
-	"fmt"
+	"encoding/base64"
 	"github.com/Ayano2000/push/internal/config"
 	"github.com/Ayano2000/push/internal/pkg/types"
-	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/pkg/errors"
 	"io"
+	"mime"
+	"path"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type MinIOStorage struct {
@@ -33,8 +35,9 @@ func NewMinIOStorage(config *config.Config) (*MinIOStorage, error) {
 	}, nil
 }
 
+// CreateBucket creates the bucket of a webhook, named by its ID
 func (m *MinIOStorage) CreateBucket(ctx context.Context, webhook types.Webhook) error {
-	exists, err := m.client.BucketExists(ctx, webhook.Name)
+	exists, err := m.client.BucketExists(ctx, webhook.ID())
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -42,7 +45,7 @@ func (m *MinIOStorage) CreateBucket(ctx context.Context, webhook types.Webhook)
 		return errors.WithStack(errors.New("webhook already exists"))
 	}
 
-	err = m.client.MakeBucket(ctx, webhook.Name, minio.MakeBucketOptions{})
+	err = m.client.MakeBucket(ctx, webhook.ID(), minio.MakeBucketOptions{})
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -50,66 +53,155 @@ func (m *MinIOStorage) CreateBucket(ctx context.Context, webhook types.Webhook)
 	return nil
 }
 
-func (m *MinIOStorage) PutObject(ctx context.Context, bucketName, payload string) error {
-	uid, err := uuid.NewRandom()
-	if err != nil {
-		return errors.WithStack(err)
+// payloadObjectNames are the object names of each kind of payload, stored
+// under the <event_id>/ prefix of the event they belong to with the
+// extension of their content type
+var payloadObjectNames = map[string]string{
+	types.PayloadPreTransform:  "raw",
+	types.PayloadPostTransform: "transformed",
+}
+
+// payloadExtensions are the object extensions of the content types payloads
+// are commonly sent with, anything else is stored as .bin
+var payloadExtensions = map[string]string{
+	"application/json":                  ".json",
+	"application/x-www-form-urlencoded": ".form",
+	"application/xml":                   ".xml",
+	"text/xml":                          ".xml",
+	"text/plain":                        ".txt",
+	"text/html":                         ".html",
+	"text/csv":                          ".csv",
+}
+
+// PutObject stores the body of a payload under the prefix of the event
+// it belongs to, keeping the content type the payload was received with
+func (m *MinIOStorage) PutObject(ctx context.Context, bucketName, eventID string, payload types.Payload) (string, error) {
+	name, ok := payloadObjectNames[payload.Kind]
+	if !ok {
+		return "", errors.WithStack(errors.Errorf("unknown payload kind %q", payload.Kind))
+	}
+
+	contentType := payload.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	extension := ".bin"
+	if media, _, err := mime.ParseMediaType(contentType); err == nil {
+		if ext, ok := payloadExtensions[media]; ok {
+			extension = ext
+		} else if strings.HasSuffix(media, "+json") {
+			extension = ".json"
+		} else if strings.HasSuffix(media, "+xml") {
+			extension = ".xml"
+		}
 	}
 
-	_, err = m.client.PutObject(
+	key := path.Join(eventID, name+extension)
+	_, err := m.client.PutObject(
 		ctx,
 		bucketName,
-		fmt.Sprintf("%s.json", uid.String()),
-		io.NopCloser(strings.NewReader(payload)),
-		int64(len(payload)),
+		key,
+		io.NopCloser(strings.NewReader(payload.Body)),
+		int64(len(payload.Body)),
 		minio.PutObjectOptions{
-			ContentType: "application/json",
-			// todo: UserTags, so we know if this is a pre or post transform value
+			ContentType: contentType,
 		},
 	)
 	if err != nil {
-		return errors.WithStack(err)
+		return "", errors.WithStack(err)
 	}
 
-	return nil
+	return key, nil
 }
 
 func (m *MinIOStorage) GetObject(ctx context.Context, bucketName, objectName string) (io.ReadCloser, error) {
 	return m.client.GetObject(ctx, bucketName, objectName, minio.GetObjectOptions{})
 }
 
-func (m *MinIOStorage) GetObjects(ctx context.Context, bucket string) ([]string, error) {
-	exists, err := m.client.BucketExists(ctx, bucket)
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
-	if !exists {
-		return nil, errors.WithStack(errors.New("webhook does not exist"))
-	}
-
-	var objects []string
-	objectChannel := m.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{})
-	for object := range objectChannel {
-		object, err := m.client.GetObject(ctx, bucket, object.Key, minio.GetObjectOptions{})
+// ReadPayloads downloads the body of each payload
+func (m *MinIOStorage) ReadPayloads(ctx context.Context, bucketName string, payloads []*types.Payload) error {
+	for _, payload := range payloads {
+		object, err := m.client.GetObject(ctx, bucketName, payload.Key, minio.GetObjectOptions{})
 		if err != nil {
-			return nil, errors.WithStack(err)
+			return errors.WithStack(err)
 		}
 
-		payload, err := io.ReadAll(object)
+		body, err := io.ReadAll(object)
+		object.Close()
 		if err != nil {
-			return nil, errors.WithStack(err)
+			return errors.WithStack(err)
 		}
 
-		objects = append(objects, string(payload))
+		if utf8.Valid(body) {
+			payload.Body = string(body)
+		} else {
+			payload.Body = base64.StdEncoding.EncodeToString(body)
+			payload.Encoding = types.PayloadEncodingBase64
+		}
 	}
-
-	return objects, nil
+	return nil
 }
 
 func (m *MinIOStorage) DeleteObject(ctx context.Context, bucketName, objectName string) error {
 	return m.client.RemoveObject(ctx, bucketName, objectName, minio.RemoveObjectOptions{})
 }
 
+// EmptyBucket removes every object in the bucket
+func (m *MinIOStorage) EmptyBucket(ctx context.Context, bucketName string) error {
+	objectChannel := m.client.ListObjects(ctx, bucketName, minio.ListObjectsOptions{Recursive: true})
+
+	// ListObjects reports failures as an object with Err set, which
+	// RemoveObjects would skip, so check them before passing them on
+	var listErr error
+	listDone := make(chan struct{})
+	removeChannel := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(listDone)
+		defer close(removeChannel)
+		for object := range objectChannel {
+			if object.Err != nil {
+				listErr = object.Err
+				continue
+			}
+			select {
+			case removeChannel <- object:
+			case <-ctx.Done():
+				listErr = ctx.Err()
+				return
+			}
+		}
+	}()
+
+	var removeErr error
+	for result := range m.client.RemoveObjects(ctx, bucketName, removeChannel, minio.RemoveObjectsOptions{}) {
+		removeErr = result.Err
+	}
+	<-listDone
+
+	if listErr != nil {
+		return errors.WithStack(listErr)
+	}
+	return errors.WithStack(removeErr)
+}
+
+// DeleteBucket empties and then removes the bucket, it is not
+// an error for the bucket to not exist
+func (m *MinIOStorage) DeleteBucket(ctx context.Context, bucketName string) error {
+	exists, err := m.client.BucketExists(ctx, bucketName)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if !exists {
+		return nil
+	}
+
+	if err = m.EmptyBucket(ctx, bucketName); err != nil {
+		return err
+	}
+
+	return errors.WithStack(m.client.RemoveBucket(ctx, bucketName))
+}
+
 func (m *MinIOStorage) GetPresignedURL(ctx context.Context, bucketName, objectName string, expires time.Duration) (string, error) {
 	presignedURL, err := m.client.PresignedGetObject(ctx, bucketName, objectName, expires, nil)
 	if err != nil {
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/pkg/errors"
+)
+
+// CountDroppedEvent records that a webhook dropped an event for reason
+func CountDroppedEvent(ctx context.Context, db Execer, webhookID, reason string) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO dropped_events (webhook_id, reason, count)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (webhook_id, reason)
+		DO UPDATE SET count = dropped_events.count + 1, last_dropped_at = now()`,
+		webhookID, reason,
+	)
+	return errors.WithStack(err)
+}
+
+// GetWebhookStats returns the number of events a webhook has stored and dropped
+func GetWebhookStats(ctx context.Context, db DatabaseHandler, webhookID string) (types.WebhookStats, error) {
+	stats := types.WebhookStats{Dropped: make([]types.DroppedEvents, 0)}
+
+	err := db.QueryRowContext(ctx, "SELECT count(*) FROM events WHERE webhook_id = $1", webhookID).Scan(&stats.Events)
+	if err != nil {
+		return stats, errors.WithStack(err)
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT reason, count, last_dropped_at
+		FROM dropped_events
+		WHERE webhook_id = $1
+		ORDER BY reason`,
+		webhookID,
+	)
+	if err != nil {
+		return stats, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var dropped types.DroppedEvents
+		if err = rows.Scan(&dropped.Reason, &dropped.Count, &dropped.LastDroppedAt); err != nil {
+			return stats, errors.WithStack(err)
+		}
+		stats.Dropped = append(stats.Dropped, dropped)
+	}
+	return stats, errors.WithStack(rows.Err())
+}
@@ -11,14 +11,18 @@ import (
 // ObjectStoreHandler defines an interface for object storage operations
 type ObjectStoreHandler interface {
 	CreateBucket(ctx context.Context, webhook types.Webhook) error
-	// PutObject uploads a file to object storage
-	PutObject(ctx context.Context, bucketName, payload string) error
+	// PutObject uploads a payload of an event to object storage, returning its key
+	PutObject(ctx context.Context, bucketName, eventID string, payload types.Payload) (string, error)
 	// GetObject downloads a file from object storage
 	GetObject(ctx context.Context, bucketName, objectName string) (io.ReadCloser, error)
-	// GetObjects returns a string array of all bucket contents
-	GetObjects(ctx context.Context, bucketName string) ([]string, error)
+	// ReadPayloads downloads the bodies of payloads
+	ReadPayloads(ctx context.Context, bucketName string, payloads []*types.Payload) error
 	// DeleteObject deletes a file from object storage
 	DeleteObject(ctx context.Context, bucketName, objectName string) error
+	// EmptyBucket deletes every file in the bucket
+	EmptyBucket(ctx context.Context, bucketName string) error
+	// DeleteBucket deletes the bucket along with its contents
+	DeleteBucket(ctx context.Context, bucketName string) error
 	// GetPresignedURL returns temporary URL for object
 	GetPresignedURL(ctx context.Context, bucketName, objectName string, expires time.Duration) (string, error)
 	// Close connection
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Ayano2000/push/internal/pkg/types"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrWebhookExists = errors.New("a webhook with the same name already exists in the team")
+	ErrRouteTaken    = errors.New("a webhook with the same method and path already exists in the team")
+)
+
+// uniqueViolation is the postgres error code of a unique constraint violation
+const uniqueViolation = "23505"
+
+// webhookRouteConstraint keeps the routes of a teams webhooks unique
+const webhookRouteConstraint = "webhooks_route_key"
+
+// WebhookColumns is the column list matching the scan order of ScanWebhook
+const WebhookColumns = `team, name, path, method, description, verification, accept_predicate, sample_rate,
+	transform_engine, jq_filter, transform_rules, output_mode, transform_timeout_ms, transform_max_output_bytes,
+	forward_to, forward_payload, preserve_payload, destinations, redact_headers`
+
+// Scanner is implemented by both *sql.Row and *sql.Rows
+type Scanner interface {
+	Scan(dest ...any) error
+}
+
+// Execer is implemented by both DatabaseHandler and *sql.Tx
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// ScanWebhook reads a webhook selected with WebhookColumns
+func ScanWebhook(row Scanner) (types.Webhook, error) {
+	var webhook types.Webhook
+	err := row.Scan(
+		&webhook.Team,
+		&webhook.Name,
+		&webhook.Path,
+		&webhook.Method,
+		&webhook.Description,
+		JSON(&webhook.Verification),
+		&webhook.AcceptPredicate,
+		&webhook.SampleRate,
+		&webhook.TransformEngine,
+		&webhook.JQFilter,
+		JSON(&webhook.TransformRules),
+		&webhook.OutputMode,
+		&webhook.TransformTimeoutMS,
+		&webhook.TransformMaxOutputBytes,
+		&webhook.ForwardTo,
+		&webhook.ForwardPayload,
+		&webhook.PreservePayload,
+		JSON(&webhook.Destinations),
+		JSON(&webhook.RedactHeaders))
+	return webhook, err
+}
+
+// InsertWebhook creates the row for a new webhook
+func InsertWebhook(ctx context.Context, db Execer, webhook types.Webhook) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO webhooks (`+WebhookColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)`,
+		webhookValues(webhook)...,
+	)
+	return webhookError(err)
+}
+
+// UpdateWebhook replaces the configuration of the webhook with the same team and name
+func UpdateWebhook(ctx context.Context, db Execer, webhook types.Webhook) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE webhooks
+		SET path = $3, method = $4, description = $5, verification = $6,
+			accept_predicate = $7, sample_rate = $8, transform_engine = $9, jq_filter = $10,
+			transform_rules = $11, output_mode = $12, transform_timeout_ms = $13,
+			transform_max_output_bytes = $14, forward_to = $15, forward_payload = $16,
+			preserve_payload = $17, destinations = $18, redact_headers = $19
+		WHERE team = $1 AND name = $2`,
+		webhookValues(webhook)...,
+	)
+	return webhookError(err)
+}
+
+// webhookError returns ErrRouteTaken or ErrWebhookExists in place
+// of the unique violation a webhook insert or update failed with
+func webhookError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != uniqueViolation {
+		return errors.WithStack(err)
+	}
+	if pgErr.ConstraintName == webhookRouteConstraint {
+		return errors.WithStack(ErrRouteTaken)
+	}
+	return errors.WithStack(ErrWebhookExists)
+}
+
+// webhookValues returns the query arguments for WebhookColumns
+func webhookValues(webhook types.Webhook) []any {
+	return []any{
+		webhook.Team,
+		webhook.Name,
+		webhook.Path,
+		webhook.Method,
+		webhook.Description,
+		JSON(webhook.Verification),
+		webhook.AcceptPredicate,
+		webhook.SampleRate,
+		webhook.TransformEngine,
+		webhook.JQFilter,
+		JSON(webhook.TransformRules),
+		webhook.OutputMode,
+		webhook.TransformTimeoutMS,
+		webhook.TransformMaxOutputBytes,
+		webhook.ForwardTo,
+		webhook.ForwardPayload,
+		webhook.PreservePayload,
+		JSON(webhook.Destinations),
+		JSON(webhook.RedactHeaders),
+	}
+}
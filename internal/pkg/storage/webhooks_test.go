@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pkg/errors"
+	"testing"
+)
+
+func TestWebhookError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{"route taken", &pgconn.PgError{Code: uniqueViolation, ConstraintName: webhookRouteConstraint}, ErrRouteTaken},
+		{"name taken", &pgconn.PgError{Code: uniqueViolation, ConstraintName: "webhooks_pkey"}, ErrWebhookExists},
+		{"other violation", &pgconn.PgError{Code: "23503", ConstraintName: webhookRouteConstraint}, nil},
+		{"other error", errors.New("connection refused"), nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := webhookError(test.err)
+			if test.expected == nil {
+				if errors.Is(err, ErrRouteTaken) || errors.Is(err, ErrWebhookExists) || !errors.Is(err, test.err) {
+					t.Errorf("expected %v to be passed through, got %v", test.err, err)
+				}
+				return
+			}
+			if !errors.Is(err, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, err)
+			}
+		})
+	}
+
+	if err := webhookError(nil); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
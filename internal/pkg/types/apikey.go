@@ -0,0 +1,64 @@
+package types
+
+import (
+	"github.com/pkg/errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Roles an API key can have on a team, each role
+// can do everything the roles before it can
+const (
+	// RoleViewer reads webhooks, their payloads, revisions and deliveries
+	RoleViewer = "viewer"
+	// RoleEditor creates and changes webhooks, previews transforms,
+	// deletes payloads and redelivers failed deliveries
+	RoleEditor = "editor"
+	// RoleAdmin deletes webhooks and rotates their signing secrets
+	RoleAdmin = "admin"
+)
+
+// roles are ordered from least to most privileged
+var roles = []string{RoleViewer, RoleEditor, RoleAdmin}
+
+// AllTeams is the team of a grant to every team
+const AllTeams = "*"
+
+// APIKey identifies a client of the management API, the key
+// itself is only known when it is created
+type APIKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Grants    []Grant   `json:"grants"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Grant gives an API key a role on the webhooks owned by a team
+type Grant struct {
+	Team string `json:"team"`
+	Role string `json:"role"`
+}
+
+// ParseGrant reads a grant written as <team>:<role>
+func ParseGrant(grant string) (Grant, error) {
+	team, role, _ := strings.Cut(grant, ":")
+	if team == "" || !slices.Contains(roles, role) {
+		return Grant{}, errors.Errorf("grant %q must be <team>:<role>, with a role of viewer, editor or admin", grant)
+	}
+	return Grant{Team: team, Role: role}, nil
+}
+
+// Can reports whether the key has role, or a role above it, on team
+func (k APIKey) Can(team, role string) bool {
+	required := slices.Index(roles, role)
+	if required < 0 || team == "" {
+		return false
+	}
+	for _, grant := range k.Grants {
+		if (grant.Team == team || grant.Team == AllTeams) && slices.Index(roles, grant.Role) >= required {
+			return true
+		}
+	}
+	return false
+}
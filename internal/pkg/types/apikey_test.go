@@ -0,0 +1,65 @@
+package types
+
+import "testing"
+
+func TestAPIKey_Can(t *testing.T) {
+	key := APIKey{Grants: []Grant{
+		{Team: "payments", Role: RoleViewer},
+		{Team: "platform", Role: RoleEditor},
+	}}
+	admin := APIKey{Grants: []Grant{{Team: AllTeams, Role: RoleAdmin}}}
+
+	tests := []struct {
+		name     string
+		key      APIKey
+		team     string
+		role     string
+		expected bool
+	}{
+		{"viewer can view", key, "payments", RoleViewer, true},
+		{"viewer can't edit", key, "payments", RoleEditor, false},
+		{"editor can view", key, "platform", RoleViewer, true},
+		{"editor can edit", key, "platform", RoleEditor, true},
+		{"editor can't administer", key, "platform", RoleAdmin, false},
+		{"no grant on team", key, "billing", RoleViewer, false},
+		{"unknown role", key, "platform", "owner", false},
+		{"webhook without team", key, "", RoleViewer, false},
+		{"all teams admin", admin, "billing", RoleAdmin, true},
+		{"all teams admin can view", admin, "payments", RoleViewer, true},
+		{"no grants", APIKey{}, "payments", RoleViewer, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := test.key.Can(test.team, test.role); actual != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseGrant(t *testing.T) {
+	tests := []struct {
+		grant    string
+		expected Grant
+		valid    bool
+	}{
+		{"payments:viewer", Grant{Team: "payments", Role: RoleViewer}, true},
+		{"*:admin", Grant{Team: AllTeams, Role: RoleAdmin}, true},
+		{"payments", Grant{}, false},
+		{":editor", Grant{}, false},
+		{"payments:owner", Grant{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.grant, func(t *testing.T) {
+			grant, err := ParseGrant(test.grant)
+			if (err == nil) != test.valid {
+				t.Fatalf("expected valid %v, got %v", test.valid, err)
+			}
+			if grant != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, grant)
+			}
+		})
+	}
+}
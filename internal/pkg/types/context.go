@@ -3,3 +3,4 @@ package types
 type LoggerContextKey string
 type MuxContextKey string
 type UrlParamContextKey string
+type APIKeyContextKey string
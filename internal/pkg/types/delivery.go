@@ -0,0 +1,60 @@
+package types
+
+import "time"
+
+// Delivery statuses
+const (
+	DeliveryStatusPending    = "pending"
+	DeliveryStatusInProgress = "in_progress"
+	DeliveryStatusSucceeded  = "succeeded"
+	DeliveryStatusFailed     = "failed"
+)
+
+// Delivery is a single payload being forwarded to a webhooks forward_to target
+type Delivery struct {
+	ID string `json:"id"`
+	// MessageID is the webhook-id the delivery is signed with, shared
+	// with the delivery it redelivers
+	MessageID     string            `json:"message_id"`
+	WebhookID     string            `json:"webhook_id"`
+	Target        string            `json:"target"`
+	ContentType   string            `json:"content_type"`
+	Payload       string            `json:"-"`
+	Status        string            `json:"status"`
+	AttemptCount  int               `json:"attempt_count"`
+	NextAttemptAt time.Time         `json:"next_attempt_at"`
+	RedeliveryOf  *string           `json:"redelivery_of,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	DeadLetter    *DeadLetter       `json:"dead_letter,omitempty"`
+	Attempts      []DeliveryAttempt `json:"attempts,omitempty"`
+}
+
+// SigningSecret is a secret the deliveries of a webhook are signed with,
+// it is only returned by the API when it is created
+type SigningSecret struct {
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SigningSecretRotation is a request to rotate the signing secret of a webhook,
+// GracePeriodSeconds is how long the secrets it replaces keep signing
+type SigningSecretRotation struct {
+	GracePeriodSeconds int `json:"grace_period_seconds"`
+}
+
+// DeadLetter is set on a Delivery which failed after exhausting its retries
+type DeadLetter struct {
+	Reason       string    `json:"reason"`
+	CreatedAt    time.Time `json:"created_at"`
+	RedeliveryID *string   `json:"redelivery_id,omitempty"`
+}
+
+// DeliveryAttempt records the outcome of one request made for a Delivery
+type DeliveryAttempt struct {
+	Attempt      int       `json:"attempt"`
+	StatusCode   int       `json:"status_code"`
+	LatencyMS    int64     `json:"latency_ms"`
+	ResponseBody string    `json:"response_body"`
+	Error        string    `json:"error,omitempty"`
+	AttemptedAt  time.Time `json:"attempted_at"`
+}
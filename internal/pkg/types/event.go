@@ -0,0 +1,55 @@
+package types
+
+import "time"
+
+// Transform statuses
+const (
+	TransformStatusPending   = "pending"
+	TransformStatusSucceeded = "succeeded"
+	TransformStatusFailed    = "failed"
+	// TransformStatusSkipped is set when the content type of
+	// the request can't be decoded for the webhooks filters
+	TransformStatusSkipped = "skipped"
+	// TransformStatusDropped is set when the webhooks filter had no
+	// results, no transformed payload is stored or forwarded
+	TransformStatusDropped = "dropped"
+)
+
+// Forward statuses, once queued an events forward status
+// is derived from the status of its deliveries
+const (
+	ForwardStatusNone      = "none"
+	ForwardStatusQueued    = "queued"
+	ForwardStatusPending   = "pending"
+	ForwardStatusSucceeded = "succeeded"
+	ForwardStatusFailed    = "failed"
+)
+
+// Event is a single request received by a webhook, along with the
+// payloads stored for it. Raw is only set if the webhook preserves payloads
+type Event struct {
+	ID              string          `json:"id"`
+	ReceivedAt      time.Time       `json:"received_at"`
+	ContentType     string          `json:"content_type"`
+	TransformStatus string          `json:"transform_status"`
+	TransformError  string          `json:"transform_error,omitempty"`
+	ForwardStatus   string          `json:"forward_status"`
+	Request         RequestMetadata `json:"request"`
+	Raw             *Payload        `json:"raw,omitempty"`
+	Transformed     *Payload        `json:"transformed,omitempty"`
+}
+
+// EventQuery selects a page of a webhooks events, oldest first
+type EventQuery struct {
+	Limit int
+	// Cursor is the NextCursor of the previous page
+	Cursor string
+	Since  time.Time
+	Until  time.Time
+}
+
+// EventPage is a page of events, NextCursor is empty on the last page
+type EventPage struct {
+	Items      []Event `json:"items"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
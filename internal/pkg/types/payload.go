@@ -0,0 +1,16 @@
+package types
+
+// Payload is a single request body stored in a webhooks bucket
+type Payload struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+	// Kind is one of PayloadPreTransform or PayloadPostTransform
+	Kind        string `json:"kind"`
+	ContentType string `json:"content_type"`
+	// Encoding is PayloadEncodingBase64 when Body isn't valid UTF-8
+	Encoding string `json:"encoding,omitempty"`
+	Body     string `json:"payload"`
+}
+
+// PayloadEncodingBase64 marks a Body which has been base64 encoded
+const PayloadEncodingBase64 = "base64"
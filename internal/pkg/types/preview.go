@@ -0,0 +1,31 @@
+package types
+
+import (
+	"encoding/json"
+	"github.com/Ayano2000/push/pkg/transformer"
+)
+
+// TransformPreview is a candidate transform to run against an inline
+// Sample and the raw payloads of the captured events in EventIDs.
+// TransformEngine and OutputMode default to those of the webhook
+type TransformPreview struct {
+	TransformEngine string             `json:"transform_engine"`
+	OutputMode      string             `json:"output_mode"`
+	JQFilter        string             `json:"jq_filter"`
+	TransformRules  []transformer.Rule `json:"transform_rules"`
+	Sample          json.RawMessage    `json:"sample"`
+	EventIDs        []string           `json:"event_ids"`
+}
+
+// TransformPreviewResult is the output of a previewed transform for a single
+// payload, or the reason it failed. EventID is empty for the inline sample,
+// Dropped is set when the filter had no results. Output is the payload which
+// would be stored and forwarded, in split mode Outputs are each of them instead
+type TransformPreviewResult struct {
+	EventID     string   `json:"event_id,omitempty"`
+	Output      string   `json:"output,omitempty"`
+	Outputs     []string `json:"outputs,omitempty"`
+	ContentType string   `json:"content_type,omitempty"`
+	Dropped     bool     `json:"dropped,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
@@ -0,0 +1,13 @@
+package types
+
+// RedactedValue replaces the values of redacted request headers
+const RedactedValue = "[REDACTED]"
+
+// RequestMetadata describes the request an event was received in
+type RequestMetadata struct {
+	Method     string              `json:"method"`
+	Path       string              `json:"path"`
+	Query      map[string][]string `json:"query"`
+	Headers    map[string][]string `json:"headers"`
+	RemoteAddr string              `json:"remote_addr"`
+}
@@ -0,0 +1,66 @@
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// WebhookRevision is an immutable snapshot of a webhooks configuration
+type WebhookRevision struct {
+	WebhookID string    `json:"webhook_id"`
+	Revision  int       `json:"revision"`
+	Config    Webhook   `json:"config"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FieldChange describes a single field which differs between two revisions
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// DiffWebhooks returns the fields changed between from and to,
+// named by their json keys and sorted by name
+func DiffWebhooks(from, to Webhook) ([]FieldChange, error) {
+	fromFields, err := toFields(from)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := toFields(to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]FieldChange, 0)
+	for field, fromValue := range fromFields {
+		if toValue := toFields[field]; !reflect.DeepEqual(fromValue, toValue) {
+			changes = append(changes, FieldChange{Field: field, From: fromValue, To: toValue})
+		}
+	}
+	for field, toValue := range toFields {
+		if _, exists := fromFields[field]; !exists {
+			changes = append(changes, FieldChange{Field: field, To: toValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes, nil
+}
+
+// toFields flattens a webhook to a map keyed by its json field names
+func toFields(webhook Webhook) (map[string]any, error) {
+	data, err := json.Marshal(webhook)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
@@ -0,0 +1,33 @@
+package types
+
+import (
+	"testing"
+)
+
+func TestDiffWebhooks(t *testing.T) {
+	from := Webhook{Name: "github", Path: "/github", Method: "POST", JQFilter: ".foo"}
+	to := Webhook{Name: "github", Path: "/github", Method: "PUT", JQFilter: ".bar"}
+
+	changes, err := DiffWebhooks(from, to)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %d: %v", len(changes), changes)
+	}
+	if changes[0].Field != "jq_filter" || changes[0].From != ".foo" || changes[0].To != ".bar" {
+		t.Errorf("unexpected change %v", changes[0])
+	}
+	if changes[1].Field != "method" || changes[1].From != "POST" || changes[1].To != "PUT" {
+		t.Errorf("unexpected change %v", changes[1])
+	}
+
+	changes, err = DiffWebhooks(from, from)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+}
@@ -0,0 +1,28 @@
+package types
+
+import "time"
+
+// Reasons an event is dropped before it is stored
+const (
+	// DropReasonRejected is counted for events the webhooks accept predicate doesn't match
+	DropReasonRejected = "rejected"
+	// DropReasonSampled is counted for accepted events left out by the webhooks sample rate
+	DropReasonSampled = "sampled"
+	// DropReasonUnverified is counted for requests which fail the webhooks signature verification
+	DropReasonUnverified = "unverified"
+)
+
+// WebhookStats counts the events a webhook has received
+type WebhookStats struct {
+	// Events is the number of events stored
+	Events int64 `json:"events"`
+	// Dropped counts the events which weren't stored, by reason
+	Dropped []DroppedEvents `json:"dropped"`
+}
+
+// DroppedEvents is the number of events a webhook has dropped for a reason
+type DroppedEvents struct {
+	Reason        string    `json:"reason"`
+	Count         int64     `json:"count"`
+	LastDroppedAt time.Time `json:"last_dropped_at"`
+}
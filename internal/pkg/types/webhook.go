@@ -1,16 +1,183 @@
 package types
 
+import (
+	"github.com/Ayano2000/push/pkg/signature"
+	"github.com/Ayano2000/push/pkg/transformer"
+)
+
+// Payload identifiers, used to select which copy of a request body
+// a webhook forwards and to tag the objects stored in minio
+const (
+	PayloadPreTransform  = "pre"
+	PayloadPostTransform = "post"
+)
+
 type Webhook struct {
-	Name            string `json:"name"`
-	Description     string `json:"description"`
-	Path            string `json:"path"`
-	Method          string `json:"method"`
+	// Name is unique within the Team owning the webhook, API
+	// keys need a role on the team to view or change the webhook
+	Name        string `json:"name"`
+	Team        string `json:"team"`
+	Description string `json:"description"`
+	Path        string `json:"path"`
+	Method      string `json:"method"`
+	// Verification checks the signature of every request before it is
+	// stored, requests without a valid signature are rejected
+	Verification *signature.Config `json:"verification"`
+	// AcceptPredicate and SampleRate drop events before they're stored,
+	// only the events the predicate matches are accepted and a SampleRate
+	// between 0 and 1 keeps that fraction of them, zero keeps every event
+	AcceptPredicate string  `json:"accept_predicate"`
+	SampleRate      float64 `json:"sample_rate"`
+	// TransformEngine is the language JQFilter, TransformRules and
+	// destination predicates are written in, jq or template
+	TransformEngine string `json:"transform_engine"`
 	JQFilter        string `json:"jq_filter"`
-	ForwardTo       string `json:"forward_to"`
-	PreservePayload bool   `json:"preserve_payload"`
+	// TransformRules are checked in order, the filter of the first rule
+	// matching a payload is used in place of JQFilter
+	TransformRules []transformer.Rule `json:"transform_rules"`
+	// OutputMode is how the results of the filter make up the
+	// transformed payload, one of the transformer.Output* modes
+	OutputMode string `json:"output_mode"`
+	// TransformTimeoutMS and TransformMaxOutputBytes limit the
+	// transform, zero uses the servers TRANSFORM_* defaults
+	TransformTimeoutMS      int    `json:"transform_timeout_ms"`
+	TransformMaxOutputBytes int    `json:"transform_max_output_bytes"`
+	ForwardTo               string `json:"forward_to"`
+	ForwardPayload          string `json:"forward_payload"`
+	PreservePayload         bool   `json:"preserve_payload"`
+	// Destinations receive the events their predicate matches,
+	// in addition to ForwardTo which receives every event
+	Destinations []Destination `json:"destinations"`
+	// RedactHeaders lists the request headers whose values
+	// are replaced before the request metadata is stored
+	RedactHeaders []string `json:"redact_headers"`
+}
+
+// Destination is a target events are forwarded to, if Predicate is set only
+// the events whose raw payload it matches are. Payload chooses which copy
+// of the event is sent, it defaults to the webhooks forward_payload
+type Destination struct {
+	URL       string `json:"url"`
+	Predicate string `json:"predicate"`
+	Payload   string `json:"payload"`
+}
+
+// ID identifies the webhook across teams, it names the webhooks bucket
+// and is how the webhooks events, deliveries and revisions refer to it
+func (w Webhook) ID() string {
+	return w.Team + "." + w.Name
+}
+
+// ForwardDestinations returns every destination of the webhook, with
+// ForwardTo as an unconditional destination if it is set
+func (w Webhook) ForwardDestinations() []Destination {
+	destinations := make([]Destination, 0, len(w.Destinations)+1)
+	if w.ForwardTo != "" {
+		destinations = append(destinations, Destination{URL: w.ForwardTo, Payload: w.ForwardPayload})
+	}
+	return append(destinations, w.Destinations...)
+}
+
+// Redacted returns a copy of the webhook with its secrets
+// replaced by RedactedValue, to be returned by the API
+func (w Webhook) Redacted() Webhook {
+	if w.Verification != nil {
+		verification := *w.Verification
+		verification.Secret = RedactedValue
+		w.Verification = &verification
+	}
+	return w
+}
+
+// WebhookUpdate holds the fields of a partial update to a webhook,
+// nil fields are left unchanged. The name and team can't be changed
+// as they identify the webhooks bucket
+type WebhookUpdate struct {
+	Description             *string             `json:"description"`
+	Path                    *string             `json:"path"`
+	Method                  *string             `json:"method"`
+	Verification            *signature.Config   `json:"verification"`
+	AcceptPredicate         *string             `json:"accept_predicate"`
+	SampleRate              *float64            `json:"sample_rate"`
+	TransformEngine         *string             `json:"transform_engine"`
+	JQFilter                *string             `json:"jq_filter"`
+	TransformRules          *[]transformer.Rule `json:"transform_rules"`
+	OutputMode              *string             `json:"output_mode"`
+	TransformTimeoutMS      *int                `json:"transform_timeout_ms"`
+	TransformMaxOutputBytes *int                `json:"transform_max_output_bytes"`
+	ForwardTo               *string             `json:"forward_to"`
+	ForwardPayload          *string             `json:"forward_payload"`
+	PreservePayload         *bool               `json:"preserve_payload"`
+	Destinations            *[]Destination      `json:"destinations"`
+	RedactHeaders           *[]string           `json:"redact_headers"`
+}
+
+// Apply returns a copy of webhook with the update applied
+func (u WebhookUpdate) Apply(webhook Webhook) Webhook {
+	if u.Description != nil {
+		webhook.Description = *u.Description
+	}
+	if u.Path != nil {
+		webhook.Path = *u.Path
+	}
+	if u.Method != nil {
+		webhook.Method = *u.Method
+	}
+	if u.Verification != nil {
+		verification := *u.Verification
+		// the redacted secret of a webhook read from the API is unchanged
+		if verification.Secret == RedactedValue && webhook.Verification != nil {
+			verification.Secret = webhook.Verification.Secret
+		}
+		webhook.Verification = &verification
+	}
+	if u.AcceptPredicate != nil {
+		webhook.AcceptPredicate = *u.AcceptPredicate
+	}
+	if u.SampleRate != nil {
+		webhook.SampleRate = *u.SampleRate
+	}
+	if u.TransformEngine != nil {
+		webhook.TransformEngine = *u.TransformEngine
+	}
+	if u.JQFilter != nil {
+		webhook.JQFilter = *u.JQFilter
+	}
+	if u.TransformRules != nil {
+		webhook.TransformRules = *u.TransformRules
+	}
+	if u.OutputMode != nil {
+		webhook.OutputMode = *u.OutputMode
+	}
+	if u.TransformTimeoutMS != nil {
+		webhook.TransformTimeoutMS = *u.TransformTimeoutMS
+	}
+	if u.TransformMaxOutputBytes != nil {
+		webhook.TransformMaxOutputBytes = *u.TransformMaxOutputBytes
+	}
+	if u.ForwardTo != nil {
+		webhook.ForwardTo = *u.ForwardTo
+	}
+	if u.ForwardPayload != nil {
+		webhook.ForwardPayload = *u.ForwardPayload
+	}
+	if u.PreservePayload != nil {
+		webhook.PreservePayload = *u.PreservePayload
+	}
+	if u.Destinations != nil {
+		webhook.Destinations = *u.Destinations
+	}
+	if u.RedactHeaders != nil {
+		webhook.RedactHeaders = *u.RedactHeaders
+	}
+	return webhook
 }
 
 // WebhookRegistrar defines methods for registering webhooks.
 type WebhookRegistrar interface {
 	RegisterWebhook(webhook Webhook)
+	UnregisterWebhook(webhook Webhook)
+	// ReplaceWebhook swaps the route of old for the route of updated,
+	// without a window where neither is registered
+	ReplaceWebhook(old Webhook, updated Webhook)
 }
@@ -0,0 +1,29 @@
+package types
+
+import (
+	"github.com/Ayano2000/push/pkg/signature"
+	"testing"
+)
+
+func TestWebhook_Redacted(t *testing.T) {
+	webhook := Webhook{Name: "github", Verification: &signature.Config{Provider: signature.ProviderGitHub, Secret: "shhh"}}
+
+	redacted := webhook.Redacted()
+	if redacted.Verification.Secret != RedactedValue {
+		t.Errorf("expected the secret to be redacted, got %s", redacted.Verification.Secret)
+	}
+	if webhook.Verification.Secret != "shhh" {
+		t.Errorf("expected the webhook to be unchanged, got %s", webhook.Verification.Secret)
+	}
+
+	// a redacted config sent back keeps the current secret
+	updated := WebhookUpdate{Verification: redacted.Verification}.Apply(webhook)
+	if updated.Verification.Secret != "shhh" {
+		t.Errorf("expected the secret to be kept, got %s", updated.Verification.Secret)
+	}
+
+	updated = WebhookUpdate{Verification: &signature.Config{Provider: signature.ProviderGitHub, Secret: "new"}}.Apply(webhook)
+	if updated.Verification.Secret != "new" {
+		t.Errorf("expected the secret to be replaced, got %s", updated.Verification.Secret)
+	}
+}
@@ -2,13 +2,18 @@ package services
 
 import (
 	"github.com/Ayano2000/push/internal/config"
+	"github.com/Ayano2000/push/internal/pkg/forwarder"
 	"github.com/Ayano2000/push/internal/pkg/storage"
+	"github.com/Ayano2000/push/pkg/transformer"
 )
 
 type Services struct {
-	Config *config.Config
-	DB     storage.DatabaseHandler
-	Minio  storage.ObjectStoreHandler
+	Config    *config.Config
+	DB        storage.DatabaseHandler
+	Minio     storage.ObjectStoreHandler
+	Forwarder *forwarder.Forwarder
+	// Programs caches the compiled JQ programs of each webhook
+	Programs *transformer.Cache
 }
 
 func NewServices(config *config.Config) (*Services, error) {
@@ -23,9 +28,11 @@ func NewServices(config *config.Config) (*Services, error) {
 	}
 
 	return &Services{
-		Config: config,
-		DB:     pgsql,
-		Minio:  minio,
+		Config:    config,
+		DB:        pgsql,
+		Minio:     minio,
+		Forwarder: forwarder.NewForwarder(config, pgsql),
+		Programs:  transformer.NewCache(),
 	}, nil
 }
 
@@ -0,0 +1,57 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Standard Webhooks headers, see https://www.standardwebhooks.com
+const (
+	HeaderWebhookID        = "webhook-id"
+	HeaderWebhookTimestamp = "webhook-timestamp"
+	HeaderWebhookSignature = "webhook-signature"
+)
+
+// secretPrefix marks Standard Webhooks secrets, the key follows it base64 encoded
+const secretPrefix = "whsec_"
+
+// NewSecret returns a random Standard Webhooks secret
+func NewSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return secretPrefix + base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Sign returns the Standard Webhooks headers of a message sent with body, the
+// signature holds one v1 signature of "<id>.<timestamp>.<body>" for every secret
+// so receivers can verify with either secret while one is being rotated
+func Sign(secrets []string, id string, timestamp time.Time, body []byte) (http.Header, error) {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+
+	signatures := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, secretPrefix))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(id + "." + unix + "."))
+		mac.Write(body)
+		signatures = append(signatures, "v1,"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	}
+
+	header := http.Header{}
+	header.Set(HeaderWebhookID, id)
+	header.Set(HeaderWebhookTimestamp, unix)
+	header.Set(HeaderWebhookSignature, strings.Join(signatures, " "))
+	return header, nil
+}
@@ -0,0 +1,46 @@
+package signature
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// the example of the Standard Webhooks specification
+	const secret = "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+	const expected = "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="
+	timestamp := time.Unix(1614265330, 0)
+	body := []byte(`{"test": 2432232314}`)
+
+	header, err := Sign([]string{secret}, "msg_p5jXN8AQM9LWM0D4loKWxJek", timestamp, body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if signature := header.Get(HeaderWebhookSignature); signature != expected {
+		t.Errorf("expected %s, got %s", expected, signature)
+	}
+	if id := header.Get(HeaderWebhookID); id != "msg_p5jXN8AQM9LWM0D4loKWxJek" {
+		t.Errorf("unexpected id %s", id)
+	}
+	if unix := header.Get(HeaderWebhookTimestamp); unix != "1614265330" {
+		t.Errorf("unexpected timestamp %s", unix)
+	}
+
+	rotated, err := NewSecret()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	header, err = Sign([]string{rotated, secret}, "msg_p5jXN8AQM9LWM0D4loKWxJek", timestamp, body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	signatures := strings.Split(header.Get(HeaderWebhookSignature), " ")
+	if len(signatures) != 2 || signatures[1] != expected {
+		t.Errorf("expected a signature for each secret, got %v", signatures)
+	}
+
+	if _, err = Sign([]string{"whsec_!"}, "msg", timestamp, body); err == nil {
+		t.Error("expected an error for a malformed secret")
+	}
+}
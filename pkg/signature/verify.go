@@ -0,0 +1,170 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Providers requests can be verified for, ProviderHMACSHA256 is a
+// hex encoded HMAC-SHA256 of the body in a configurable header
+const (
+	ProviderHMACSHA256 = "hmac-sha256"
+	ProviderGitHub     = "github"
+	ProviderStripe     = "stripe"
+	ProviderSlack      = "slack"
+	ProviderShopify    = "shopify"
+)
+
+// DefaultTolerance is how far the timestamp of a signed
+// request can be from the time it is verified by default
+const DefaultTolerance = 5 * time.Minute
+
+// Errors returned by Verify for requests which fail verification
+var (
+	ErrMissingSignature = errors.New("signature is missing")
+	ErrInvalidSignature = errors.New("signature doesn't match")
+	ErrInvalidTimestamp = errors.New("timestamp is missing or outside the tolerance")
+)
+
+// Config is how the requests of a webhook are signed
+type Config struct {
+	Provider string `json:"provider"`
+	Secret   string `json:"secret"`
+	// Header and Prefix locate the signature of ProviderHMACSHA256
+	// requests, which follows Prefix in Header, e.g. "sha256="
+	Header string `json:"header,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	// ToleranceSeconds bounds the age of stripe and slack
+	// requests, zero uses DefaultTolerance
+	ToleranceSeconds int `json:"tolerance_seconds,omitempty"`
+}
+
+// Validate returns an error if the config can't verify requests
+func (c Config) Validate() error {
+	if c.Secret == "" {
+		return errors.New("secret is required")
+	}
+	if c.ToleranceSeconds < 0 {
+		return errors.New("tolerance_seconds can't be negative")
+	}
+
+	switch c.Provider {
+	case ProviderHMACSHA256:
+		if c.Header == "" {
+			return errors.New("header is required")
+		}
+	case ProviderGitHub, ProviderStripe, ProviderSlack, ProviderShopify:
+	default:
+		return errors.Errorf("unknown provider %q", c.Provider)
+	}
+	return nil
+}
+
+// Verify checks the signature of a request received with header and body at now
+func (c Config) Verify(header http.Header, body []byte, now time.Time) error {
+	switch c.Provider {
+	case ProviderHMACSHA256:
+		return verifyHex(header.Get(c.Header), c.Prefix, sign(c.Secret, body))
+	case ProviderGitHub:
+		return verifyHex(header.Get("X-Hub-Signature-256"), "sha256=", sign(c.Secret, body))
+	case ProviderShopify:
+		return verifyBase64(header.Get("X-Shopify-Hmac-Sha256"), sign(c.Secret, body))
+	case ProviderSlack:
+		timestamp := header.Get("X-Slack-Request-Timestamp")
+		if err := c.checkTimestamp(timestamp, now); err != nil {
+			return err
+		}
+		return verifyHex(header.Get("X-Slack-Signature"), "v0=", sign(c.Secret, []byte("v0:"+timestamp+":"), body))
+	case ProviderStripe:
+		return c.verifyStripe(header.Get("Stripe-Signature"), body, now)
+	default:
+		return errors.Errorf("unknown provider %q", c.Provider)
+	}
+}
+
+// verifyStripe checks a Stripe-Signature header, t=<timestamp>,v1=<signature>
+// where any of the v1 signatures may match as Stripe sends several while
+// rolling a secret
+func (c Config) verifyStripe(value string, body []byte, now time.Time) error {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(value, ",") {
+		key, val, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = val
+		case "v1":
+			signatures = append(signatures, val)
+		}
+	}
+	if len(signatures) == 0 {
+		return ErrMissingSignature
+	}
+	if err := c.checkTimestamp(timestamp, now); err != nil {
+		return err
+	}
+
+	expected := sign(c.Secret, []byte(timestamp+"."), body)
+	for _, signature := range signatures {
+		if verifyHex(signature, "", expected) == nil {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// checkTimestamp returns ErrInvalidTimestamp unless timestamp
+// is unix seconds within the tolerance of now
+func (c Config) checkTimestamp(timestamp string, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+
+	tolerance := DefaultTolerance
+	if c.ToleranceSeconds > 0 {
+		tolerance = time.Duration(c.ToleranceSeconds) * time.Second
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return ErrInvalidTimestamp
+	}
+	return nil
+}
+
+// sign returns the HMAC-SHA256 of parts concatenated
+func sign(secret string, parts ...[]byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	for _, part := range parts {
+		mac.Write(part)
+	}
+	return mac.Sum(nil)
+}
+
+func verifyHex(value, prefix string, expected []byte) error {
+	if value == "" {
+		return ErrMissingSignature
+	}
+	signature, err := hex.DecodeString(strings.TrimPrefix(value, prefix))
+	if err != nil || !strings.HasPrefix(value, prefix) || !hmac.Equal(signature, expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func verifyBase64(value string, expected []byte) error {
+	if value == "" {
+		return ErrMissingSignature
+	}
+	signature, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || !hmac.Equal(signature, expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
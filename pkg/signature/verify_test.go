@@ -0,0 +1,167 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func hmacSHA256(secret, message string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+func TestVerify(t *testing.T) {
+	const secret = "shhh"
+	const body = `{"id": 1}`
+	now := time.Unix(1700000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	stale := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+
+	bodySignature := hex.EncodeToString(hmacSHA256(secret, body))
+	stripeSignature := hex.EncodeToString(hmacSHA256(secret, timestamp+"."+body))
+	staleStripeSignature := hex.EncodeToString(hmacSHA256(secret, stale+"."+body))
+	slackSignature := hex.EncodeToString(hmacSHA256(secret, "v0:"+timestamp+":"+body))
+
+	tests := []struct {
+		name     string
+		config   Config
+		headers  map[string]string
+		expected error
+	}{
+		{
+			"generic",
+			Config{Provider: ProviderHMACSHA256, Secret: secret, Header: "X-Signature", Prefix: "sha256="},
+			map[string]string{"X-Signature": "sha256=" + bodySignature},
+			nil,
+		},
+		{
+			"generic without prefix",
+			Config{Provider: ProviderHMACSHA256, Secret: secret, Header: "X-Signature", Prefix: "sha256="},
+			map[string]string{"X-Signature": bodySignature},
+			ErrInvalidSignature,
+		},
+		{
+			"generic missing header",
+			Config{Provider: ProviderHMACSHA256, Secret: secret, Header: "X-Signature"},
+			nil,
+			ErrMissingSignature,
+		},
+		{
+			"github",
+			Config{Provider: ProviderGitHub, Secret: secret},
+			map[string]string{"X-Hub-Signature-256": "sha256=" + bodySignature},
+			nil,
+		},
+		{
+			"github wrong secret",
+			Config{Provider: ProviderGitHub, Secret: "other"},
+			map[string]string{"X-Hub-Signature-256": "sha256=" + bodySignature},
+			ErrInvalidSignature,
+		},
+		{
+			"shopify",
+			Config{Provider: ProviderShopify, Secret: secret},
+			map[string]string{"X-Shopify-Hmac-Sha256": base64.StdEncoding.EncodeToString(hmacSHA256(secret, body))},
+			nil,
+		},
+		{
+			"shopify hex",
+			Config{Provider: ProviderShopify, Secret: secret},
+			map[string]string{"X-Shopify-Hmac-Sha256": bodySignature},
+			ErrInvalidSignature,
+		},
+		{
+			"slack",
+			Config{Provider: ProviderSlack, Secret: secret},
+			map[string]string{"X-Slack-Signature": "v0=" + slackSignature, "X-Slack-Request-Timestamp": timestamp},
+			nil,
+		},
+		{
+			"slack stale",
+			Config{Provider: ProviderSlack, Secret: secret},
+			map[string]string{"X-Slack-Signature": "v0=" + slackSignature, "X-Slack-Request-Timestamp": stale},
+			ErrInvalidTimestamp,
+		},
+		{
+			"stripe",
+			Config{Provider: ProviderStripe, Secret: secret},
+			map[string]string{"Stripe-Signature": "t=" + timestamp + ",v1=" + stripeSignature},
+			nil,
+		},
+		{
+			"stripe rolled secret",
+			Config{Provider: ProviderStripe, Secret: secret},
+			map[string]string{"Stripe-Signature": "t=" + timestamp + ",v1=00ff,v1=" + stripeSignature + ",v0=abc"},
+			nil,
+		},
+		{
+			"stripe stale",
+			Config{Provider: ProviderStripe, Secret: secret},
+			map[string]string{"Stripe-Signature": "t=" + stale + ",v1=" + staleStripeSignature},
+			ErrInvalidTimestamp,
+		},
+		{
+			"stripe within tolerance",
+			Config{Provider: ProviderStripe, Secret: secret, ToleranceSeconds: 3600},
+			map[string]string{"Stripe-Signature": "t=" + stale + ",v1=" + staleStripeSignature},
+			nil,
+		},
+		{
+			"stripe timestamp changed",
+			Config{Provider: ProviderStripe, Secret: secret},
+			map[string]string{"Stripe-Signature": "t=" + timestamp + ",v1=" + staleStripeSignature},
+			ErrInvalidSignature,
+		},
+		{
+			"stripe missing signature",
+			Config{Provider: ProviderStripe, Secret: secret},
+			map[string]string{"Stripe-Signature": "t=" + timestamp},
+			ErrMissingSignature,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := http.Header{}
+			for name, value := range test.headers {
+				header.Set(name, value)
+			}
+
+			err := test.config.Verify(header, []byte(body), now)
+			if !errors.Is(err, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		valid  bool
+	}{
+		{"github", Config{Provider: ProviderGitHub, Secret: "s"}, true},
+		{"generic", Config{Provider: ProviderHMACSHA256, Secret: "s", Header: "X-Signature"}, true},
+		{"generic without header", Config{Provider: ProviderHMACSHA256, Secret: "s"}, false},
+		{"without secret", Config{Provider: ProviderStripe}, false},
+		{"negative tolerance", Config{Provider: ProviderSlack, Secret: "s", ToleranceSeconds: -1}, false},
+		{"unknown provider", Config{Provider: "gitlab", Secret: "s"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.config.Validate(); (err == nil) != test.valid {
+				t.Errorf("expected valid %v, got %v", test.valid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package transformer
+
+import (
+	"sync"
+)
+
+// Cache holds the compiled programs of each webhooks expressions, so they
+// are only compiled the first time a webhook uses them. Programs are keyed
+// by their engine and expression, so a changed filter is compiled again,
+// Invalidate drops the programs a webhook no longer uses
+type Cache struct {
+	mu       sync.RWMutex
+	programs map[string]map[programKey]Program
+}
+
+type programKey struct {
+	engine     string
+	expression string
+}
+
+// NewCache creates an empty Cache
+func NewCache() *Cache {
+	return &Cache{programs: make(map[string]map[programKey]Program)}
+}
+
+// Transformer returns the named engine, using the compiled programs of webhook
+func (c *Cache) Transformer(webhook string, name string) (Transformer, error) {
+	if name == "" {
+		name = EngineJQ
+	}
+	compile, err := engineCompiler(name)
+	if err != nil {
+		return nil, err
+	}
+	return engine{compile: c.compiler(webhook, name, compile)}, nil
+}
+
+// Invalidate drops the compiled programs of webhook, it should
+// be called whenever the webhook is changed or deleted
+func (c *Cache) Invalidate(webhook string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.programs, webhook)
+}
+
+// compiler returns a compileFunc which caches programs under webhook
+func (c *Cache) compiler(webhook, name string, compile compileFunc) compileFunc {
+	return func(expression string) (Program, error) {
+		key := programKey{engine: name, expression: expression}
+		c.mu.RLock()
+		program, ok := c.programs[webhook][key]
+		c.mu.RUnlock()
+		if ok {
+			return program, nil
+		}
+
+		program, err := compile(expression)
+		if err != nil {
+			return nil, err
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.programs[webhook] == nil {
+			c.programs[webhook] = make(map[programKey]Program)
+		}
+		c.programs[webhook][key] = program
+		return program, nil
+	}
+}
@@ -0,0 +1,69 @@
+package transformer
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCache_TransformRules(t *testing.T) {
+	cache := NewCache()
+	jq, err := cache.Transformer("webhook", EngineJQ)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	payload := `{"foo": "bar", "baz": "bat"}`
+
+	result, err := jq.TransformRules(context.Background(), Input{Body: payload, ContentType: JSONContentType}, nil, ".foo", Limits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Body != `"bar"` {
+		t.Errorf("expected \"bar\", got %s", result.Body)
+	}
+	if _, ok := cache.programs["webhook"][programKey{engine: EngineJQ, expression: ".foo"}]; !ok {
+		t.Errorf("expected .foo to be cached")
+	}
+
+	// a changed filter is compiled rather than reusing the old program
+	result, err = jq.TransformRules(context.Background(), Input{Body: payload, ContentType: JSONContentType}, nil, ".baz", Limits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Body != `"bat"` {
+		t.Errorf("expected \"bat\", got %s", result.Body)
+	}
+
+	cache.Invalidate("webhook")
+	if _, ok := cache.programs["webhook"]; ok {
+		t.Errorf("expected programs to be dropped")
+	}
+
+	if _, err = jq.TransformRules(context.Background(), Input{Body: payload, ContentType: JSONContentType}, nil, ".[", Limits{}); err == nil {
+		t.Errorf("expected invalid filter error")
+	}
+}
+
+const benchmarkPayload = `{"action": "opened", "number": 1, "pull_request": {"title": "fix", "user": {"login": "octocat"}, "labels": [{"name": "bug"}, {"name": "p1"}]}}`
+const benchmarkFilter = `{title: .pull_request.title, author: .pull_request.user.login, labels: [.pull_request.labels[].name]}`
+
+func BenchmarkTransform(b *testing.B) {
+	ctx := context.Background()
+	for i := 0; i < b.N; i++ {
+		if _, err := Transform(ctx, benchmarkPayload, benchmarkFilter); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCache_TransformRules(b *testing.B) {
+	ctx := context.Background()
+	jq, err := NewCache().Transformer("webhook", EngineJQ)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		if _, err := jq.TransformRules(ctx, Input{Body: benchmarkPayload, ContentType: JSONContentType}, nil, benchmarkFilter, Limits{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package transformer
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"github.com/pkg/errors"
+	"io"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+// JSONContentType is the content type of filter output
+const JSONContentType = "application/json"
+
+// Decode decodes a payload of the given content type into a value JQ can run
+// against, reporting false if the content type has no structured decoding.
+// JSON, form encoded and XML payloads are decoded, payloads without a
+// content type are assumed to be JSON
+func Decode(payload string, contentType string) (any, bool, error) {
+	switch mediaType(contentType) {
+	case "", JSONContentType:
+		var object any
+		if err := json.Unmarshal([]byte(payload), &object); err != nil {
+			return nil, true, err
+		}
+		return object, true, nil
+	case "application/x-www-form-urlencoded":
+		object, err := decodeForm(payload)
+		return object, true, err
+	case "application/xml", "text/xml":
+		object, err := decodeXML(payload)
+		return object, true, err
+	default:
+		return nil, false, nil
+	}
+}
+
+// mediaType returns the lowercase media type of contentType without its parameters,
+// structured syntax suffixes such as application/cloudevents+json are reduced to
+// the type they are based on
+func mediaType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	media, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	switch {
+	case strings.HasSuffix(media, "+json"):
+		return JSONContentType
+	case strings.HasSuffix(media, "+xml"):
+		return "application/xml"
+	}
+	return media
+}
+
+// decodeForm decodes a form into an object of its fields, fields
+// with more than one value are decoded as an array of them
+func decodeForm(payload string) (any, error) {
+	values, err := url.ParseQuery(payload)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	object := make(map[string]any, len(values))
+	for key, value := range values {
+		if len(value) == 1 {
+			object[key] = value[0]
+			continue
+		}
+		items := make([]any, len(value))
+		for i := range value {
+			items[i] = value[i]
+		}
+		object[key] = items
+	}
+	return object, nil
+}
+
+// decodeXML decodes an XML document into an object of its root element. Elements
+// with only text are decoded as a string, others as an object of their child
+// elements, with attributes prefixed by @ and any text under #text. Repeated
+// child elements are decoded as an array of them
+func decodeXML(payload string) (any, error) {
+	decoder := xml.NewDecoder(strings.NewReader(payload))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil, errors.WithStack(errors.New("xml document has no root element"))
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			value, err := decodeElement(decoder, start)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]any{start.Name.Local: value}, nil
+		}
+	}
+}
+
+func decodeElement(decoder *xml.Decoder, start xml.StartElement) (any, error) {
+	object := make(map[string]any)
+	for _, attr := range start.Attr {
+		object["@"+attr.Name.Local] = attr.Value
+	}
+
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			value, err := decodeElement(decoder, token)
+			if err != nil {
+				return nil, err
+			}
+			name := token.Name.Local
+			switch existing := object[name].(type) {
+			case nil:
+				object[name] = value
+			case []any:
+				object[name] = append(existing, value)
+			default:
+				object[name] = []any{existing, value}
+			}
+		case xml.CharData:
+			text.Write(token)
+		case xml.EndElement:
+			content := strings.TrimSpace(text.String())
+			if len(object) == 0 {
+				return content, nil
+			}
+			if content != "" {
+				object["#text"] = content
+			}
+			return object, nil
+		}
+	}
+}
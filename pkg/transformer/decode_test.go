@@ -0,0 +1,75 @@
+package transformer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name        string
+		payload     string
+		contentType string
+		expected    any
+		structured  bool
+	}{
+		{"json", `{"id": 1}`, "application/json; charset=utf-8", map[string]any{"id": float64(1)}, true},
+		{"no content type", `[1]`, "", []any{float64(1)}, true},
+		{"json suffix", `{"id": 1}`, "application/cloudevents+json", map[string]any{"id": float64(1)}, true},
+		{
+			"form",
+			"command=%2Fdeploy&text=api&channel=a&channel=b",
+			"application/x-www-form-urlencoded",
+			map[string]any{"command": "/deploy", "text": "api", "channel": []any{"a", "b"}},
+			true,
+		},
+		{
+			"xml",
+			`<?xml version="1.0"?><order id="7"><item>a</item><item>b</item><note lang="en">hi</note><total>3</total></order>`,
+			"text/xml",
+			map[string]any{"order": map[string]any{
+				"@id":   "7",
+				"item":  []any{"a", "b"},
+				"note":  map[string]any{"@lang": "en", "#text": "hi"},
+				"total": "3",
+			}},
+			true,
+		},
+		{"text", "hello", "text/plain", nil, false},
+		{"binary", "\x00\x01", "application/octet-stream", nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			object, structured, err := Decode(test.payload, test.contentType)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if structured != test.structured {
+				t.Errorf("expected structured %t, got %t", test.structured, structured)
+			}
+			if !reflect.DeepEqual(object, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, object)
+			}
+		})
+	}
+}
+
+func TestTransformRules_ContentTypes(t *testing.T) {
+	result, err := TransformRules(context.Background(), Input{Body: "text=hi", ContentType: "application/x-www-form-urlencoded"}, nil, ".text", Limits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Body != `"hi"` || result.ContentType != JSONContentType {
+		t.Errorf("expected \"hi\" as json, got %s as %s", result.Body, result.ContentType)
+	}
+
+	result, err = TransformRules(context.Background(), Input{Body: "hello", ContentType: "text/plain"}, nil, ".text", Limits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Skipped || result.Body != "hello" || result.ContentType != "text/plain" {
+		t.Errorf("expected text to be passed through, got %+v", result)
+	}
+}
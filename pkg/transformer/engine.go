@@ -0,0 +1,96 @@
+package transformer
+
+import (
+	"context"
+	"github.com/pkg/errors"
+)
+
+// Engine names, selected per webhook by its transform_engine
+const (
+	EngineJQ       = "jq"
+	EngineTemplate = "template"
+)
+
+// Transformer is a transform engine, running filters and predicates
+// written in its language against decoded payloads
+type Transformer interface {
+	// Validate returns an error if expression doesn't compile, the empty expression is valid
+	Validate(expression string) error
+	// TransformRules runs the filter of the first rule matching the payload, falling
+	// back to filter if none of them match. The payload is decoded according to its
+	// content type, payloads which can't be decoded are passed through. A *LimitError
+	// is returned if the transform exceeds limits
+	TransformRules(ctx context.Context, input Input, rules []Rule, filter string, limits Limits) (Result, error)
+	// Match reports whether predicate matches the payload, an empty predicate matches
+	// every payload and payloads which can't be decoded match no other predicate.
+	// The timeout of limits applies, and its max output to what templates can write
+	Match(ctx context.Context, input Input, predicate string, limits Limits) (bool, error)
+}
+
+// Program is a compiled filter or predicate
+type Program interface {
+	// Run runs the program against a decoded payload and the request it was received in,
+	// programs which build their output as they run stop once it exceeds limits
+	Run(ctx context.Context, object any, input Input, limits Limits) Iter
+}
+
+// Iter yields the results of a Program, a result may be an error
+type Iter interface {
+	Next() (any, bool)
+}
+
+// compileFunc returns the compiled program of an expression
+type compileFunc func(expression string) (Program, error)
+
+// engine implements Transformer for a compileFunc
+type engine struct {
+	compile compileFunc
+}
+
+// The engines, compiling expressions every time they are used
+var (
+	JQ       Transformer = engine{compile: compileJQ}
+	Template Transformer = engine{compile: compileTemplate}
+)
+
+// engines are the compilers of each engine, by name
+var engines = map[string]compileFunc{
+	EngineJQ:       compileJQ,
+	EngineTemplate: compileTemplate,
+}
+
+// Engine returns the engine with the given name, the empty name is the JQ engine
+func Engine(name string) (Transformer, error) {
+	compile, err := engineCompiler(name)
+	if err != nil {
+		return nil, err
+	}
+	return engine{compile: compile}, nil
+}
+
+func engineCompiler(name string) (compileFunc, error) {
+	if name == "" {
+		name = EngineJQ
+	}
+	compile, ok := engines[name]
+	if !ok {
+		return nil, errors.WithStack(errors.Errorf("unknown transform engine %q", name))
+	}
+	return compile, nil
+}
+
+func (e engine) Validate(expression string) error {
+	if expression == "" {
+		return nil
+	}
+	_, err := e.compile(expression)
+	return err
+}
+
+func (e engine) TransformRules(ctx context.Context, input Input, rules []Rule, filter string, limits Limits) (Result, error) {
+	return transformRules(ctx, e.compile, input, rules, filter, limits)
+}
+
+func (e engine) Match(ctx context.Context, input Input, predicate string, limits Limits) (bool, error) {
+	return matchPayload(ctx, e.compile, input, predicate, limits)
+}
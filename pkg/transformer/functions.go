@@ -0,0 +1,129 @@
+package transformer
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/itchyny/gojq"
+	"hash"
+	"math"
+	"strings"
+	"time"
+)
+
+// variables are the names of the variables filters can use, in the order
+// their values are passed when running a program
+var variables = []string{"$headers", "$query"}
+
+// options are the compiler options adding the function library and variables to every program
+var options = []gojq.CompilerOption{
+	gojq.WithVariables(variables),
+	gojq.WithFunction("sha1", 0, 0, digest(sha1.New)),
+	gojq.WithFunction("sha256", 0, 0, digest(sha256.New)),
+	gojq.WithFunction("sha512", 0, 0, digest(sha512.New)),
+	gojq.WithFunction("hmac_sha1", 1, 1, hmacDigest(sha1.New)),
+	gojq.WithFunction("hmac_sha256", 1, 1, hmacDigest(sha256.New)),
+	gojq.WithFunction("hmac_sha512", 1, 1, hmacDigest(sha512.New)),
+	gojq.WithFunction("base64url_encode", 0, 0, base64urlEncode),
+	gojq.WithFunction("base64url_decode", 0, 0, base64urlDecode),
+	gojq.WithFunction("uuid", 0, 0, newUUID),
+	gojq.WithFunction("parse_rfc3339", 0, 0, parseRFC3339),
+	gojq.WithFunction("format_rfc3339", 0, 0, formatRFC3339),
+}
+
+// digest returns a function hashing its string input, as lowercase hex
+func digest(h func() hash.Hash) func(any, []any) any {
+	return func(input any, _ []any) any {
+		s, ok := input.(string)
+		if !ok {
+			return typeError("hash", input, "string")
+		}
+		sum := h()
+		sum.Write([]byte(s))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+}
+
+// hmacDigest returns a function signing its string input with the key
+// it is passed, as lowercase hex
+func hmacDigest(h func() hash.Hash) func(any, []any) any {
+	return func(input any, args []any) any {
+		s, ok := input.(string)
+		if !ok {
+			return typeError("hmac", input, "string")
+		}
+		key, ok := args[0].(string)
+		if !ok {
+			return typeError("hmac key", args[0], "string")
+		}
+		mac := hmac.New(h, []byte(key))
+		mac.Write([]byte(s))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+}
+
+func base64urlEncode(input any, _ []any) any {
+	s, ok := input.(string)
+	if !ok {
+		return typeError("base64url_encode", input, "string")
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+// base64urlDecode accepts input with or without padding
+func base64urlDecode(input any, _ []any) any {
+	s, ok := input.(string)
+	if !ok {
+		return typeError("base64url_decode", input, "string")
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return fmt.Errorf("base64url_decode: %w", err)
+	}
+	return string(decoded)
+}
+
+func newUUID(any, []any) any {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+	return id.String()
+}
+
+// parseRFC3339 returns the unix time of an RFC 3339 timestamp, in seconds
+func parseRFC3339(input any, _ []any) any {
+	s, ok := input.(string)
+	if !ok {
+		return typeError("parse_rfc3339", input, "string")
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return fmt.Errorf("parse_rfc3339: %w", err)
+	}
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+// formatRFC3339 formats a unix time in seconds as an RFC 3339 timestamp in UTC
+func formatRFC3339(input any, _ []any) any {
+	var seconds float64
+	switch n := input.(type) {
+	case int:
+		seconds = float64(n)
+	case float64:
+		seconds = n
+	default:
+		return typeError("format_rfc3339", input, "number")
+	}
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*float64(time.Second))).UTC().Format(time.RFC3339Nano)
+}
+
+func typeError(name string, value any, expected string) error {
+	return fmt.Errorf("%s cannot be applied to %T, expected a %s", name, value, expected)
+}
@@ -0,0 +1,58 @@
+package transformer
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+func TestFunctions(t *testing.T) {
+	input := Input{
+		Body:        `{"body": "hello", "at": "2024-05-01T12:30:00.5+02:00", "unix": 1714559400}`,
+		ContentType: JSONContentType,
+		Headers:     http.Header{"X-Github-Event": {"push"}, "Accept": {"a", "b"}},
+		Query:       url.Values{"id": {"7"}, "tag": {"x", "y"}},
+	}
+
+	tests := []struct {
+		filter   string
+		expected string
+	}{
+		{".body | sha1", `"aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"`},
+		{".body | sha256", `"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"`},
+		{`.body | hmac_sha256("key")`, `"9307b3b915efb5171ff14d8cb55fbcc798c6c0ef1456d66ded1a6aa723a58b7b"`},
+		{".body | base64url_encode", `"aGVsbG8"`},
+		{`"aGVsbG8=" | base64url_decode`, `"hello"`},
+		{".at | parse_rfc3339", `1714559400.5`},
+		{".unix | format_rfc3339", `"2024-05-01T10:30:00Z"`},
+		{`$headers["x-github-event"]`, `"push"`},
+		{`$headers.accept`, `"a, b"`},
+		{`[$query.id, $query.tag]`, `["7",["x","y"]]`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.filter, func(t *testing.T) {
+			result, err := TransformRules(context.Background(), input, nil, test.filter, Limits{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Body != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, result.Body)
+			}
+		})
+	}
+
+	result, err := TransformRules(context.Background(), input, nil, "uuid", Limits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !regexp.MustCompile(`^"[0-9a-f-]{36}"$`).MatchString(result.Body) {
+		t.Errorf("expected a uuid, got %s", result.Body)
+	}
+
+	if _, err = TransformRules(context.Background(), input, nil, ".unix | sha256", Limits{}); err == nil {
+		t.Errorf("expected a type error")
+	}
+}
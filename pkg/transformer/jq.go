@@ -0,0 +1,60 @@
+package transformer
+
+import (
+	"context"
+	"github.com/itchyny/gojq"
+	"strings"
+)
+
+// jqProgram is a compiled JQ expression
+type jqProgram struct {
+	code *gojq.Code
+}
+
+// compileJQ parses and compiles expression with the function library and variables
+func compileJQ(expression string) (Program, error) {
+	query, err := gojq.Parse(expression)
+	if err != nil {
+		return nil, err
+	}
+	code, err := gojq.Compile(query, options...)
+	if err != nil {
+		return nil, err
+	}
+	return jqProgram{code: code}, nil
+}
+
+func (p jqProgram) Run(ctx context.Context, object any, input Input, _ Limits) Iter {
+	return p.code.RunWithContext(ctx, object, variableValues(input)...)
+}
+
+// variableValues returns the values of variables for input, header names
+// are lowercase and repeated headers are joined with commas, query
+// parameters with more than one value are an array of them
+func variableValues(input Input) []any {
+	return []any{requestHeaders(input), requestQuery(input)}
+}
+
+func requestHeaders(input Input) map[string]any {
+	headers := make(map[string]any, len(input.Headers))
+	for name, values := range input.Headers {
+		headers[strings.ToLower(name)] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
+func requestQuery(input Input) map[string]any {
+	query := make(map[string]any, len(input.Query))
+	for name, values := range input.Query {
+		if len(values) == 1 {
+			query[name] = values[0]
+			continue
+		}
+		items := make([]any, len(values))
+		for i := range values {
+			items[i] = values[i]
+		}
+		query[name] = items
+	}
+	return query
+}
@@ -0,0 +1,56 @@
+package transformer
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// Limits bounds the resources a transform can use, zero values are unlimited
+type Limits struct {
+	// Timeout is how long the predicates and filter can run for in total
+	Timeout time.Duration
+	// MaxOutput is the size in bytes of the largest output allowed
+	MaxOutput int
+}
+
+// context applies the timeout to ctx
+func (l Limits) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if l.Timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, l.Timeout)
+}
+
+// check replaces the error of a run which was stopped by the timeout with a *LimitError
+func (l Limits) check(ctx context.Context, err error) error {
+	if err != nil && l.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &LimitError{Limit: LimitTimeout, Value: l.Timeout.String()}
+	}
+	return err
+}
+
+// Limit names, reported by LimitError
+const (
+	LimitTimeout   = "timeout"
+	LimitMaxOutput = "max_output"
+)
+
+// LimitError is returned when a transform exceeds one of its Limits
+type LimitError struct {
+	Limit string
+	// Value is the limit which was exceeded
+	Value string
+}
+
+func (e *LimitError) Error() string {
+	switch e.Limit {
+	case LimitTimeout:
+		return fmt.Sprintf("transform did not finish within %s", e.Value)
+	case LimitMaxOutput:
+		return fmt.Sprintf("transform output exceeded %s bytes", e.Value)
+	default:
+		return fmt.Sprintf("transform exceeded its %s limit of %s", e.Limit, e.Value)
+	}
+}
@@ -0,0 +1,205 @@
+package transformer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode"
+)
+
+// tickFunc is called at the start of every range iteration and template
+// invocation, failing once the context of the run is done. Templates can't
+// be interrupted otherwise, so it bounds how long they keep running
+const tickFunc = "_tick"
+
+// maxFormatWidth is the largest width or precision printf can pad to
+const maxFormatWidth = 1000
+
+// templateFuncs are the functions templates can use, header and query
+// are bound to the request the payload was received in on every run
+var templateFuncs = template.FuncMap{
+	"json":             templateJSON,
+	"header":           func(string) any { return nil },
+	"query":            func(string) any { return nil },
+	"sha1":             templateFunc(digest(sha1.New), 0),
+	"sha256":           templateFunc(digest(sha256.New), 0),
+	"sha512":           templateFunc(digest(sha512.New), 0),
+	"hmac_sha1":        templateFunc(hmacDigest(sha1.New), 1),
+	"hmac_sha256":      templateFunc(hmacDigest(sha256.New), 1),
+	"hmac_sha512":      templateFunc(hmacDigest(sha512.New), 1),
+	"base64url_encode": templateFunc(base64urlEncode, 0),
+	"base64url_decode": templateFunc(base64urlDecode, 0),
+	"uuid":             func() (any, error) { return result(newUUID(nil, nil)) },
+	"parse_rfc3339":    templateFunc(parseRFC3339, 0),
+	"format_rfc3339":   templateFunc(formatRFC3339, 0),
+	"printf":           templatePrintf,
+	tickFunc:           func() string { return "" },
+}
+
+// templateProgram is a parsed text/template, executed with the decoded payload
+// as dot. Output which is valid JSON is used as is, anything else is a string
+type templateProgram struct {
+	tmpl *template.Template
+}
+
+func compileTemplate(expression string) (Program, error) {
+	tmpl, err := template.New("transform").Option("missingkey=zero").Funcs(templateFuncs).Parse(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	tick, err := template.New(tickFunc).Funcs(templateFuncs).Parse("{{" + tickFunc + "}}")
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			insertTicks(t.Tree.Root, tick.Tree.Root.Nodes[0])
+		}
+	}
+	return templateProgram{tmpl: tmpl}, nil
+}
+
+// insertTicks adds tick to the start of list and of every range body within
+// it, list is the body of a template so recursive templates tick as well
+func insertTicks(list *parse.ListNode, tick parse.Node) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		switch node := node.(type) {
+		case *parse.IfNode:
+			insertTicks(node.List, tick)
+			insertTicks(node.ElseList, tick)
+		case *parse.WithNode:
+			insertTicks(node.List, tick)
+			insertTicks(node.ElseList, tick)
+		case *parse.RangeNode:
+			insertTicks(node.List, tick)
+			insertTicks(node.ElseList, tick)
+		case *parse.ListNode:
+			insertTicks(node, tick)
+		}
+	}
+	list.Nodes = append([]parse.Node{tick.Copy()}, list.Nodes...)
+}
+
+func (p templateProgram) Run(ctx context.Context, object any, input Input, limits Limits) Iter {
+	tmpl, err := p.tmpl.Clone()
+	if err != nil {
+		return &valueIter{values: []any{err}}
+	}
+	headers, query := requestHeaders(input), requestQuery(input)
+	tmpl.Funcs(template.FuncMap{
+		"header": func(name string) any { return headers[strings.ToLower(name)] },
+		"query":  func(name string) any { return query[name] },
+		tickFunc: func() (string, error) { return "", ctx.Err() },
+	})
+
+	var output bytes.Buffer
+	if err = tmpl.Execute(&limitWriter{w: &output, limit: limits.MaxOutput}, object); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return &valueIter{values: []any{err}}
+	}
+
+	text := strings.TrimSpace(output.String())
+	switch {
+	case text == "":
+		return &valueIter{}
+	case json.Valid([]byte(text)):
+		return &valueIter{values: []any{json.RawMessage(text)}}
+	default:
+		return &valueIter{values: []any{text}}
+	}
+}
+
+// limitWriter fails with a *LimitError once more than limit bytes have been
+// written to it, stopping the template writing them. Zero is unlimited
+type limitWriter struct {
+	w       io.Writer
+	limit   int
+	written int
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	w.written += len(p)
+	if w.limit > 0 && w.written > w.limit {
+		return 0, &LimitError{Limit: LimitMaxOutput, Value: strconv.Itoa(w.limit)}
+	}
+	return w.w.Write(p)
+}
+
+// templatePrintf is fmt.Sprintf, rejecting formats which pad to more than
+// maxFormatWidth or take their width from an argument
+func templatePrintf(format string, args ...any) (string, error) {
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		width := 0
+		for i++; i < len(format) && !unicode.IsLetter(rune(format[i])) && format[i] != '%'; i++ {
+			switch c := format[i]; {
+			case c == '*':
+				return "", fmt.Errorf("printf widths can't be arguments")
+			case c >= '0' && c <= '9':
+				width = width*10 + int(c-'0')
+				if width > maxFormatWidth {
+					return "", fmt.Errorf("printf widths can't exceed %d", maxFormatWidth)
+				}
+			default:
+				width = 0
+			}
+		}
+	}
+	return fmt.Sprintf(format, args...), nil
+}
+
+// valueIter yields a fixed list of values
+type valueIter struct {
+	values []any
+}
+
+func (i *valueIter) Next() (any, bool) {
+	if len(i.values) == 0 {
+		return nil, false
+	}
+	value := i.values[0]
+	i.values = i.values[1:]
+	return value, true
+}
+
+// templateJSON marshals a value, for embedding objects in JSON templates
+func templateJSON(value any) (string, error) {
+	b, err := json.Marshal(value)
+	return string(b), err
+}
+
+// templateFunc adapts a JQ function to a template function, the input is the
+// last argument so it can be piped to, after arity other arguments
+func templateFunc(f func(any, []any) any, arity int) func(...any) (any, error) {
+	return func(args ...any) (any, error) {
+		if len(args) != arity+1 {
+			return nil, fmt.Errorf("expected %d arguments, got %d", arity+1, len(args))
+		}
+		return result(f(args[arity], args[:arity]))
+	}
+}
+
+// result splits the error out of a JQ function result
+func result(value any) (any, error) {
+	if err, ok := value.(error); ok {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,158 @@
+package transformer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestTemplate(t *testing.T) {
+	input := Input{
+		Body:        `{"action": "opened", "pull_request": {"title": "fix", "labels": ["bug"]}}`,
+		ContentType: JSONContentType,
+		Headers:     http.Header{"X-Github-Event": {"pull_request"}},
+	}
+
+	tests := []struct {
+		name     string
+		filter   string
+		expected string
+	}{
+		{"json output", `{"title": "{{.pull_request.title}}", "labels": {{json .pull_request.labels}}}`, `{"title":"fix","labels":["bug"]}`},
+		{"text output", `{{.action}} {{.pull_request.title}}`, `"opened fix"`},
+		{"request header", `{{header "X-GitHub-Event"}}`, `"pull_request"`},
+		{"functions", `{{.action | sha1}}`, `"590abaace41bdb1047b7713b47b0a4c3489ca80f"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := Template.TransformRules(context.Background(), input, nil, test.filter, Limits{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Body != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, result.Body)
+			}
+		})
+	}
+
+	rules := []Rule{
+		{Predicate: `{{eq .action "closed"}}`, Filter: `"closed"`},
+		{Predicate: `{{eq .action "opened"}}`, Filter: `"opened"`},
+	}
+	result, err := Template.TransformRules(context.Background(), input, rules, "", Limits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Body != `"opened"` {
+		t.Errorf("expected the second rule to match, got %s", result.Body)
+	}
+
+	if err = Template.Validate(`{{.action`); err == nil {
+		t.Errorf("expected an invalid template")
+	}
+
+	_, err = Template.TransformRules(context.Background(), input, nil, `{{range 1000000000}}x{{end}}`, Limits{Timeout: 50 * time.Millisecond})
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != LimitTimeout {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
+
+func TestTemplate_TimeoutStopsExecution(t *testing.T) {
+	input := Input{Body: `{"items": [1, 2, 3]}`, ContentType: JSONContentType}
+
+	tests := []struct {
+		name   string
+		filter string
+	}{
+		{"range over an integer", `{{range 100000000000}}{{end}}`},
+		{"nested range", `{{range 100000}}{{range 100000}}{{end}}{{end}}`},
+		{"recursive template", `{{define "a"}}{{template "a" .}}{{template "a" .}}{{end}}{{template "a" .}}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+
+			_, err := Template.TransformRules(context.Background(), input, nil, test.filter, Limits{Timeout: 50 * time.Millisecond})
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) || limitErr.Limit != LimitTimeout {
+				t.Fatalf("expected a timeout, got %v", err)
+			}
+
+			// the timer of the timeout may still be winding down
+			deadline := time.Now().Add(time.Second)
+			for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if after := runtime.NumGoroutine(); after > before {
+				t.Errorf("expected no goroutines to be left running, %d before and %d after", before, after)
+			}
+		})
+	}
+}
+
+func TestTemplate_MaxOutputStopsExecution(t *testing.T) {
+	input := Input{Body: `{"items": [1, 2, 3]}`, ContentType: JSONContentType}
+
+	tests := []struct {
+		name      string
+		filter    string
+		predicate string
+	}{
+		{"filter", `{{range 100000000000}}{{printf "%1000d" 1}}{{end}}`, ""},
+		{"predicate", `{{.items}}`, `{{range 100000000000}}{{printf "%1000d" 1}}{{end}}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var rules []Rule
+			if test.predicate != "" {
+				rules = []Rule{{Predicate: test.predicate, Filter: test.filter}}
+			}
+
+			start := time.Now()
+			_, err := Template.TransformRules(context.Background(), input, rules, test.filter, Limits{Timeout: 10 * time.Second, MaxOutput: 1024})
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) || limitErr.Limit != LimitMaxOutput {
+				t.Fatalf("expected the output limit to be exceeded, got %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("expected the template to stop once its output exceeded the limit, it ran for %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestTemplatePrintf(t *testing.T) {
+	input := Input{Body: `{"n": 7}`, ContentType: JSONContentType}
+
+	result, err := Template.TransformRules(context.Background(), input, nil, `{{printf "%03d-%.2f" 7 1.5}}`, Limits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Body != `"007-1.50"` {
+		t.Errorf("expected a formatted string, got %s", result.Body)
+	}
+
+	for _, filter := range []string{`{{printf "%01000000000d" 1}}`, `{{printf "%*d" 1000000000 1}}`, `{{printf "%.9999f" 1.5}}`} {
+		if _, err = Template.TransformRules(context.Background(), input, nil, filter, Limits{}); err == nil {
+			t.Errorf("%s: expected the width to be rejected", filter)
+		}
+	}
+}
+
+func TestEngine(t *testing.T) {
+	for _, name := range []string{"", EngineJQ, EngineTemplate} {
+		if _, err := Engine(name); err != nil {
+			t.Errorf("%q: unexpected error: %v", name, err)
+		}
+	}
+	if _, err := Engine("jsonata"); err == nil {
+		t.Errorf("expected an unknown engine error")
+	}
+}
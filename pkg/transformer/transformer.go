@@ -5,26 +5,220 @@ import (
 	"encoding/json"
 	"github.com/itchyny/gojq"
 	"github.com/pkg/errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 )
 
-// Transform will take a json payload, and a JQ filter,
+// Output modes, how the results of a filter make up the transformed payload.
+// Without a mode a single result is used as is and multiple are collected
+// into an array
+const (
+	OutputArray  = "array"
+	OutputNDJSON = "ndjson"
+	OutputSplit  = "split"
+)
+
+// NDJSONContentType is the content type of OutputNDJSON payloads
+const NDJSONContentType = "application/x-ndjson"
+
+// Rule applies Filter to the payloads Predicate matches, a payload
+// matches when the predicates first result is neither false nor null
+type Rule struct {
+	Predicate string `json:"predicate"`
+	Filter    string `json:"filter"`
+}
+
+// Input is a payload to transform, along with the request it was received
+// in. Headers and Query are available to filters as $headers and $query
+type Input struct {
+	Body        string
+	ContentType string
+	Headers     http.Header
+	Query       url.Values
+}
+
+// Result is the output of a transform
+type Result struct {
+	Body        string
+	ContentType string
+	// Outputs are the results of the filter marshalled as JSON, it is
+	// nil when no filter was run as Body is then the payload unchanged
+	Outputs []string
+	// Skipped is set when there were filters to run but the payloads
+	// content type can't be decoded for them, Body is then the payload
+	Skipped bool
+}
+
+// Transform will take a json payload, and a JQ filter, returning
+// an empty string if the filter has no results
 func Transform(ctx context.Context, payload string, filter string) (string, error) {
+	result, err := JQ.TransformRules(ctx, Input{Body: payload, ContentType: JSONContentType}, nil, filter, Limits{})
+	return result.Body, err
+}
+
+// TransformRules is TransformRules of the JQ engine
+func TransformRules(ctx context.Context, input Input, rules []Rule, filter string, limits Limits) (Result, error) {
+	return JQ.TransformRules(ctx, input, rules, filter, limits)
+}
+
+// Match is Match of the JQ engine
+func Match(ctx context.Context, input Input, predicate string, limits Limits) (bool, error) {
+	return JQ.Match(ctx, input, predicate, limits)
+}
+
+func transformRules(ctx context.Context, compile compileFunc, input Input, rules []Rule, filter string, limits Limits) (Result, error) {
+	unchanged := Result{Body: input.Body, ContentType: input.ContentType}
+	if len(rules) == 0 && filter == "" {
+		return unchanged, nil
+	}
+
+	object, structured, err := Decode(input.Body, input.ContentType)
+	if err != nil {
+		return Result{}, err
+	}
+	if !structured {
+		unchanged.Skipped = true
+		return unchanged, nil
+	}
+
+	ctx, cancel := limits.context(ctx)
+	defer cancel()
+	filter, err = selectFilter(ctx, compile, object, input, rules, filter, limits)
+	if err != nil {
+		return Result{}, limits.check(ctx, err)
+	}
 	if filter == "" {
-		return payload, nil
+		return unchanged, nil
 	}
 
-	query, err := gojq.Parse(filter)
+	program, err := compile(filter)
 	if err != nil {
-		return "", err
+		return Result{}, err
 	}
-	var object any
-	err = json.Unmarshal([]byte(payload), &object)
+	outputs, err := apply(ctx, program, object, input, limits)
 	if err != nil {
-		return "", err
+		return Result{}, limits.check(ctx, err)
+	}
+
+	result := Result{ContentType: JSONContentType, Outputs: outputs}
+	switch len(outputs) {
+	case 0:
+	case 1:
+		result.Body = outputs[0]
+	default:
+		result.Body = jsonArray(outputs)
+		if limits.MaxOutput > 0 && len(result.Body) > limits.MaxOutput {
+			return Result{}, &LimitError{Limit: LimitMaxOutput, Value: strconv.Itoa(limits.MaxOutput)}
+		}
+	}
+	return result, nil
+}
+
+// Format returns the payloads making up the result in the given output mode,
+// there are none when the filter had no results and one for every result
+// in OutputSplit mode. A result no filter was run for is returned as is
+func (r Result) Format(mode string) []Result {
+	if r.Outputs == nil {
+		return []Result{r}
+	}
+	if len(r.Outputs) == 0 {
+		return nil
 	}
 
-	var results []interface{}
-	iter := query.RunWithContext(ctx, object)
+	switch mode {
+	case OutputArray:
+		return []Result{{Body: jsonArray(r.Outputs), ContentType: JSONContentType, Outputs: r.Outputs}}
+	case OutputNDJSON:
+		return []Result{{Body: strings.Join(r.Outputs, "\n") + "\n", ContentType: NDJSONContentType, Outputs: r.Outputs}}
+	case OutputSplit:
+		results := make([]Result, 0, len(r.Outputs))
+		for _, output := range r.Outputs {
+			results = append(results, Result{Body: output, ContentType: JSONContentType, Outputs: []string{output}})
+		}
+		return results
+	default:
+		return []Result{r}
+	}
+}
+
+// IsValidOutputMode returns true for each output mode, and for no mode
+func IsValidOutputMode(mode string) bool {
+	switch mode {
+	case "", OutputArray, OutputNDJSON, OutputSplit:
+		return true
+	default:
+		return false
+	}
+}
+
+func jsonArray(outputs []string) string {
+	return "[" + strings.Join(outputs, ",") + "]"
+}
+
+// selectFilter returns the filter of the first rule matching object, or filter if none do
+func selectFilter(ctx context.Context, compile compileFunc, object any, input Input, rules []Rule, filter string, limits Limits) (string, error) {
+	for i, rule := range rules {
+		program, err := compile(rule.Predicate)
+		if err != nil {
+			return "", errors.Wrapf(err, "rule %d predicate", i)
+		}
+		matched, err := match(ctx, program, object, input, limits)
+		if err != nil {
+			return "", errors.Wrapf(err, "rule %d predicate", i)
+		}
+		if matched {
+			return rule.Filter, nil
+		}
+	}
+	return filter, nil
+}
+
+func matchPayload(ctx context.Context, compile compileFunc, input Input, predicate string, limits Limits) (bool, error) {
+	if predicate == "" {
+		return true, nil
+	}
+
+	program, err := compile(predicate)
+	if err != nil {
+		return false, err
+	}
+	object, structured, err := Decode(input.Body, input.ContentType)
+	if err != nil || !structured {
+		return false, err
+	}
+
+	ctx, cancel := limits.context(ctx)
+	defer cancel()
+	matched, err := match(ctx, program, object, input, limits)
+	return matched, limits.check(ctx, err)
+}
+
+// match reports whether the first result of program is truthy
+func match(ctx context.Context, program Program, object any, input Input, limits Limits) (bool, error) {
+	value, ok := program.Run(ctx, object, input, limits).Next()
+	if !ok {
+		return false, nil
+	}
+	switch value := value.(type) {
+	case error:
+		return false, value
+	case json.RawMessage:
+		return string(value) != "false" && string(value) != "null", nil
+	default:
+		return value != nil && value != false, nil
+	}
+}
+
+// apply runs program against object, returning each result marshalled as JSON.
+// Results are marshalled as they are produced so a filter exceeding maxOutput,
+// counting the separators they're joined with, is stopped early. Zero is unlimited
+func apply(ctx context.Context, program Program, object any, input Input, limits Limits) ([]string, error) {
+	maxOutput := limits.MaxOutput
+	results := make([]string, 0, 1)
+	size := 0
+	iter := program.Run(ctx, object, input, limits)
 	for {
 		value, hasNextValue := iter.Next()
 		if !hasNextValue {
@@ -36,43 +230,39 @@ func Transform(ctx context.Context, payload string, filter string) (string, erro
 			if errors.As(err, &haltError) && haltError.Value() == nil {
 				break
 			}
-			return "", err
+			return nil, err
 		}
 
-		results = append(results, value)
-	}
-
-	if len(results) == 0 {
-		return "", errors.WithStack(errors.New("no results found"))
-	}
-
-	var marshal interface{}
-	switch len(results) {
-	case 0:
-		marshal = struct{}{}
-	case 1:
-		marshal = results[0]
-	default:
-		marshal = results
-	}
-
-	result, err := json.Marshal(marshal)
-	if err != nil {
-		return "", err
+		result, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		size += len(result) + 1
+		if maxOutput > 0 && size-1 > maxOutput {
+			return nil, &LimitError{Limit: LimitMaxOutput, Value: strconv.Itoa(maxOutput)}
+		}
+		results = append(results, string(result))
 	}
 
-	return string(result), nil
+	return results, nil
 }
 
 // IsValidFilter will return true if the filter is empty
 func IsValidFilter(filter string) (bool, error) {
-	if filter == "" {
-		return true, nil
-	}
-	_, err := gojq.Parse(filter)
-	if err != nil {
+	if err := JQ.Validate(filter); err != nil {
 		return false, err
 	}
-
 	return true, nil
 }
+
+// ValidateRule returns an error if the rule has no predicate
+// or if either of its expressions don't compile with t
+func ValidateRule(t Transformer, rule Rule) error {
+	if rule.Predicate == "" {
+		return errors.WithStack(errors.New("predicate is required"))
+	}
+	if err := t.Validate(rule.Predicate); err != nil {
+		return err
+	}
+	return t.Validate(rule.Filter)
+}
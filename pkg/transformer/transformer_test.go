@@ -2,7 +2,9 @@ package transformer
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 )
 
 func TestTransform_ValidFilter(t *testing.T) {
@@ -43,3 +45,141 @@ func TestTransform_ValidFilter(t *testing.T) {
 		t.Errorf("expected %s, got %s", expected, result)
 	}
 }
+
+func TestTransformRules(t *testing.T) {
+	rules := []Rule{
+		{Predicate: `.action == "opened"`, Filter: ".pull_request.title"},
+		{Predicate: ".ref", Filter: ".ref"},
+		{Predicate: ".skip", Filter: ""},
+	}
+
+	tests := []struct {
+		name     string
+		payload  string
+		expected string
+	}{
+		{"first matching rule", `{"action": "opened", "ref": "main", "pull_request": {"title": "fix"}}`, `"fix"`},
+		{"later matching rule", `{"action": "closed", "ref": "main"}`, `"main"`},
+		{"rule without filter", `{"skip": true, "id": 1}`, `{"skip": true, "id": 1}`},
+		{"default filter", `{"id": 1}`, `1`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := TransformRules(context.Background(), Input{Body: test.payload, ContentType: JSONContentType}, rules, ".id", Limits{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Body != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, result.Body)
+			}
+		})
+	}
+
+	_, err := TransformRules(context.Background(), Input{Body: `{"id": 1}`, ContentType: JSONContentType}, []Rule{{Predicate: `error("bad")`}}, "", Limits{})
+	if err == nil {
+		t.Errorf("expected predicate error")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	payload := `{"type": "payment.failed", "amount": 10}`
+
+	tests := []struct {
+		predicate string
+		expected  bool
+	}{
+		{"", true},
+		{`.type == "payment.failed"`, true},
+		{`.type == "payment.succeeded"`, false},
+		{".missing", false},
+		{".amount", true},
+	}
+
+	for _, test := range tests {
+		matched, err := Match(context.Background(), Input{Body: payload, ContentType: JSONContentType}, test.predicate, Limits{})
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.predicate, err)
+		}
+		if matched != test.expected {
+			t.Errorf("%q: expected %t, got %t", test.predicate, test.expected, matched)
+		}
+	}
+}
+
+func TestTransformRules_Limits(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		limits Limits
+		limit  string
+	}{
+		{"infinite recursion", "def f: f; f", Limits{Timeout: 50 * time.Millisecond}, LimitTimeout},
+		{"large range", "[range(1e9)]", Limits{Timeout: 50 * time.Millisecond}, LimitTimeout},
+		{"single large output", `[range(100)] | tostring`, Limits{MaxOutput: 64}, LimitMaxOutput},
+		{"many outputs", "range(100)", Limits{MaxOutput: 64}, LimitMaxOutput},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := TransformRules(context.Background(), Input{Body: `{}`, ContentType: JSONContentType}, nil, test.filter, test.limits)
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("expected a limit error, got %v", err)
+			}
+			if limitErr.Limit != test.limit {
+				t.Errorf("expected %s limit, got %s", test.limit, limitErr.Limit)
+			}
+		})
+	}
+
+	result, err := TransformRules(context.Background(), Input{Body: `{"id": 1}`, ContentType: JSONContentType}, nil, ".id", Limits{Timeout: time.Second, MaxOutput: 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Body != "1" {
+		t.Errorf("expected 1, got %s", result.Body)
+	}
+}
+
+func TestResult_Format(t *testing.T) {
+	tests := []struct {
+		name         string
+		filter       string
+		mode         string
+		bodies       []string
+		contentTypes []string
+	}{
+		{"no mode single", ".a", "", []string{`1`}, []string{JSONContentType}},
+		{"no mode multiple", ".[]", "", []string{`[1,2]`}, []string{JSONContentType}},
+		{"array single", ".a", OutputArray, []string{`[1]`}, []string{JSONContentType}},
+		{"array multiple", ".[]", OutputArray, []string{`[1,2]`}, []string{JSONContentType}},
+		{"ndjson", ".[]", OutputNDJSON, []string{"1\n2\n"}, []string{NDJSONContentType}},
+		{"split", ".[]", OutputSplit, []string{`1`, `2`}, []string{JSONContentType, JSONContentType}},
+		{"empty", "empty", OutputArray, nil, nil},
+		{"no filter", "", OutputSplit, []string{`{"a": 1, "b": 2}`}, []string{JSONContentType}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := Input{Body: `{"a": 1, "b": 2}`, ContentType: JSONContentType}
+			result, err := TransformRules(context.Background(), input, nil, test.filter, Limits{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			outputs := result.Format(test.mode)
+			if len(outputs) != len(test.bodies) {
+				t.Fatalf("expected %d outputs, got %d", len(test.bodies), len(outputs))
+			}
+			for i, output := range outputs {
+				if output.Body != test.bodies[i] {
+					t.Errorf("expected %q, got %q", test.bodies[i], output.Body)
+				}
+				if output.ContentType != test.contentTypes[i] {
+					t.Errorf("expected content type %s, got %s", test.contentTypes[i], output.ContentType)
+				}
+			}
+		})
+	}
+}
@@ -1,11 +1,165 @@
+DROP TABLE IF EXISTS api_key_grants;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS signing_secrets;
+DROP TABLE IF EXISTS dropped_events;
+DROP TABLE IF EXISTS webhook_revisions;
+DROP TABLE IF EXISTS dead_letters;
+DROP TABLE IF EXISTS delivery_attempts;
+DROP TABLE IF EXISTS deliveries;
+DROP TABLE IF EXISTS events;
 DROP TABLE IF EXISTS webhooks;
+
+-- keys of the management API, stored as the SHA-256 of the key,
+-- created with the apikey command
+CREATE TABLE IF NOT EXISTS api_keys
+(
+    id           UUID         NOT NULL PRIMARY KEY,
+    name         VARCHAR(255) NOT NULL,
+    key_hash     CHAR(64)     NOT NULL UNIQUE,
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ
+);
+
+-- the role each api key has on a team, a team of '*' grants the role on every team
+CREATE TABLE IF NOT EXISTS api_key_grants
+(
+    api_key_id UUID         NOT NULL REFERENCES api_keys (id) ON DELETE CASCADE,
+    team       VARCHAR(255) NOT NULL,
+    role       VARCHAR(16)  NOT NULL,
+    PRIMARY KEY (api_key_id, team)
+);
+
+-- webhooks are namespaced by their team, id is the <team>.<name> other
+-- tables reference the webhook by and its minio bucket is named
 CREATE TABLE IF NOT EXISTS webhooks
 (
-    name             VARCHAR(255) NOT NULL PRIMARY KEY,
+    team             VARCHAR(63)  NOT NULL,
+    name             VARCHAR(63)  NOT NULL,
+    id               VARCHAR(127) NOT NULL GENERATED ALWAYS AS (team || '.' || name) STORED UNIQUE,
     path             VARCHAR(255) NOT NULL,
     method           VARCHAR(10)  NOT NULL,
     description      VARCHAR(255),
+    verification     JSONB,
+    accept_predicate TEXT         NOT NULL DEFAULT '',
+    sample_rate      DOUBLE PRECISION NOT NULL DEFAULT 0,
+    transform_engine VARCHAR(16)  NOT NULL DEFAULT 'jq',
     jq_filter        TEXT,
+    transform_rules  JSONB        NOT NULL DEFAULT '[]',
+    output_mode      VARCHAR(8)   NOT NULL DEFAULT '',
+    transform_timeout_ms       INTEGER NOT NULL DEFAULT 0,
+    transform_max_output_bytes INTEGER NOT NULL DEFAULT 0,
     forward_to       TEXT,
-    preserve_payload BOOLEAN
-);
\ No newline at end of file
+    forward_payload  VARCHAR(4)   NOT NULL DEFAULT 'post',
+    preserve_payload BOOLEAN,
+    destinations     JSONB        NOT NULL DEFAULT '[]',
+    redact_headers   JSONB        NOT NULL DEFAULT '[]',
+    PRIMARY KEY (team, name),
+    -- the route of a webhook is its path prefixed with its team
+    CONSTRAINT webhooks_route_key UNIQUE (team, method, path)
+);
+
+-- every configuration a webhook has had, a new revision is
+-- added on create, update and rollback and never modified
+CREATE TABLE IF NOT EXISTS webhook_revisions
+(
+    webhook_id   VARCHAR(127) NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    revision     INTEGER      NOT NULL,
+    config       JSONB        NOT NULL,
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    PRIMARY KEY (webhook_id, revision)
+);
+
+-- the secrets deliveries are signed with, a rotated secret
+-- keeps signing alongside its replacement until expires_at
+CREATE TABLE IF NOT EXISTS signing_secrets
+(
+    webhook_id   VARCHAR(127) NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    secret       TEXT         NOT NULL,
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    expires_at   TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS signing_secrets_webhook_id_idx ON signing_secrets (webhook_id);
+
+-- the number of events each webhook dropped before storing them,
+-- by reason, as they aren't indexed in events
+CREATE TABLE IF NOT EXISTS dropped_events
+(
+    webhook_id      VARCHAR(127) NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    reason          VARCHAR(16)  NOT NULL,
+    count           BIGINT       NOT NULL DEFAULT 0,
+    last_dropped_at TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    PRIMARY KEY (webhook_id, reason)
+);
+
+-- an index of every request received by a webhook, the events payloads
+-- are stored in minio under the <event_id>/ prefix of its bucket
+CREATE TABLE IF NOT EXISTS events
+(
+    id               UUID         NOT NULL PRIMARY KEY,
+    webhook_id       VARCHAR(127) NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    received_at      TIMESTAMPTZ  NOT NULL,
+    content_type     TEXT         NOT NULL,
+    method           VARCHAR(10)  NOT NULL,
+    path             TEXT         NOT NULL,
+    query            JSONB        NOT NULL,
+    headers          JSONB        NOT NULL,
+    remote_addr      TEXT         NOT NULL,
+    raw_key          TEXT,
+    raw_size         BIGINT,
+    transformed_key  TEXT,
+    transformed_size BIGINT,
+    transformed_content_type TEXT,
+    transform_status VARCHAR(16)  NOT NULL,
+    transform_error  TEXT         NOT NULL DEFAULT '',
+    forward_status   VARCHAR(16)  NOT NULL
+);
+CREATE INDEX IF NOT EXISTS events_webhook_id_idx ON events (webhook_id, id);
+CREATE INDEX IF NOT EXISTS events_received_at_idx ON events (webhook_id, received_at);
+
+-- deliveries doubles as the outbound queue, workers claim pending rows
+-- with SELECT ... FOR UPDATE SKIP LOCKED and hold them until locked_until.
+-- message_id is the id deliveries are signed with, a redelivery keeps the
+-- message_id of the delivery it replays so receivers can deduplicate it
+CREATE TABLE IF NOT EXISTS deliveries
+(
+    id              UUID         NOT NULL PRIMARY KEY,
+    message_id      UUID         NOT NULL,
+    webhook_id      VARCHAR(127) NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_id        UUID REFERENCES events (id) ON DELETE SET NULL,
+    target          TEXT         NOT NULL,
+    content_type    TEXT         NOT NULL,
+    payload         BYTEA        NOT NULL,
+    status          VARCHAR(16)  NOT NULL,
+    attempts        INTEGER      NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    locked_until    TIMESTAMPTZ,
+    redelivery_of   UUID REFERENCES deliveries (id) ON DELETE SET NULL,
+    created_at      TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ  NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS deliveries_webhook_id_idx ON deliveries (webhook_id, created_at);
+CREATE INDEX IF NOT EXISTS deliveries_event_id_idx ON deliveries (event_id);
+CREATE INDEX IF NOT EXISTS deliveries_queue_idx ON deliveries (next_attempt_at) WHERE status IN ('pending', 'in_progress');
+
+CREATE TABLE IF NOT EXISTS delivery_attempts
+(
+    delivery_id   UUID        NOT NULL REFERENCES deliveries (id) ON DELETE CASCADE,
+    attempt       INTEGER     NOT NULL,
+    status_code   INTEGER     NOT NULL,
+    latency_ms    BIGINT      NOT NULL,
+    response_body TEXT        NOT NULL,
+    error         TEXT        NOT NULL,
+    attempted_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (delivery_id, attempt)
+);
+
+-- deliveries which exhausted their retries, redelivery_id is set
+-- once a delivery has been queued again through the API
+CREATE TABLE IF NOT EXISTS dead_letters
+(
+    delivery_id   UUID        NOT NULL PRIMARY KEY REFERENCES deliveries (id) ON DELETE CASCADE,
+    reason        TEXT        NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    redelivery_id UUID REFERENCES deliveries (id) ON DELETE SET NULL
+);